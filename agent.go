@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/patrickmcnamara/portunus/vault"
+)
+
+var (
+	errAgentNoKey = errors.New("agent holds no key for the vault")
+	errAgentStart = errors.New("agent didn't start")
+	errAgentPath  = errors.New("agent socket or its directory isn't private to this user")
+	errAgentPeer  = errors.New("agent connection is from another user")
+	errStdioAgent = errors.New("a vault piped through stdin and stdout can't be unlocked")
+	errBadTTL     = errors.New("-ttl must be positive")
+)
+
+// agentRequest is sent to the agent, one per connection
+type agentRequest struct {
+	Op    string        `json:"op"` // "ping", "add", "get" or "lock"
+	Vault string        `json:"vault,omitempty"`
+	Salt  []byte        `json:"salt,omitempty"`
+	Key   []byte        `json:"key,omitempty"`
+	TTL   time.Duration `json:"ttl,omitempty"`
+}
+
+// agentResponse answers an agentRequest
+type agentResponse struct {
+	Key   []byte `json:"key,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// agentKey is a vault key held by the agent until it expires
+type agentKey struct {
+	salt    []byte
+	key     []byte
+	expires time.Time
+}
+
+// agentSocket returns the agent's socket, from PORTUNUS_AGENT_SOCK or else in
+// $XDG_RUNTIME_DIR or a private directory in the temporary directory.
+func agentSocket() string {
+	if sock := os.Getenv("PORTUNUS_AGENT_SOCK"); sock != "" {
+		return sock
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("portunus-%d", os.Getuid()))
+	}
+	return filepath.Join(dir, "portunus-agent.sock")
+}
+
+// checkAgentPath refuses the agent socket unless its directory is a real
+// directory only the user can use and the socket, if there is one yet, is
+// the user's and not a symlink, so that no one else can pose as the agent.
+func checkAgentPath(sock string) error {
+	dir := filepath.Dir(sock)
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() || !ownedByUser(fi) || (runtime.GOOS != "windows" && fi.Mode().Perm() != 0700) {
+		return fmt.Errorf("%w: %s", errAgentPath, dir)
+	}
+	fi, err = os.Lstat(sock)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 || !ownedByUser(fi) {
+		return fmt.Errorf("%w: %s", errAgentPath, sock)
+	}
+	return nil
+}
+
+// callAgent sends req to the agent and returns its response.
+func callAgent(req agentRequest) (agentResponse, error) {
+	var resp agentResponse
+	sock := agentSocket()
+	if err := checkAgentPath(sock); err != nil {
+		return resp, err
+	}
+	conn, err := net.DialTimeout("unix", sock, time.Second)
+	if err != nil {
+		return resp, err
+	}
+	defer conn.Close()
+	if err := checkPeer(conn); err != nil {
+		return resp, err
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return resp, err
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return resp, err
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// openWithAgent opens the vault with its key from the agent, failing if no
+// agent is running or it holds no key for the vault.
+func openWithAgent() (*vault.Vault, error) {
+	if vaultFile == stdioVault {
+		return nil, errStdioAgent
+	}
+	path, err := filepath.Abs(vaultFile)
+	if err != nil {
+		return nil, err
+	}
+	return vault.OpenStoreKey(vaultStore(), func(salt []byte) ([]byte, error) {
+		resp, err := callAgent(agentRequest{Op: "get", Vault: path, Salt: salt})
+		if err != nil {
+			return nil, err
+		}
+		if resp.Key == nil {
+			return nil, errAgentNoKey
+		}
+		return resp.Key, nil
+	})
+}
+
+// startAgent starts 'portunus agent' in the background and waits for it to
+// answer.
+func startAgent() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "agent")
+	// the agent only needs the derived keys it is sent, not the password
+	cmd.Env = childEnv()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	cmd.Process.Release()
+	for i := 0; i < 50; i++ {
+		_, err := callAgent(agentRequest{Op: "ping"})
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errAgentPath) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errAgentStart
+}
+
+// agent holds vault keys in memory for other portunus processes
+type agent struct {
+	keys map[string]agentKey
+	lock sync.Mutex
+
+	// done is closed once by the first lock request, to stop the agent
+	done chan struct{}
+	stop sync.Once
+}
+
+// runAgent serves vault keys on the agent socket until they are locked, or it
+// holds none once they have expired or a minute after it started.
+func runAgent() error {
+	sock := agentSocket()
+	dir := filepath.Dir(sock)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := checkAgentPath(sock); err != nil {
+		return err
+	}
+	if _, err := callAgent(agentRequest{Op: "ping"}); err == nil {
+		return nil
+	}
+	os.Remove(sock)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	if err := os.Chmod(sock, 0600); err != nil {
+		return err
+	}
+	signal.Ignore(syscall.SIGHUP)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	a := &agent{keys: make(map[string]agentKey), done: make(chan struct{})}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go a.serve(conn)
+		}
+	}()
+	idle := time.Now().Add(time.Minute)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-sigs:
+			a.clear()
+			return nil
+		case <-a.done:
+			return nil
+		case now := <-tick.C:
+			if a.expire(now) == 0 && now.After(idle) {
+				return nil
+			}
+		}
+	}
+}
+
+// serve answers one request on conn.
+func (a *agent) serve(conn net.Conn) {
+	defer conn.Close()
+	if checkPeer(conn) != nil {
+		return
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	var req agentRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	var resp agentResponse
+	a.lock.Lock()
+	switch req.Op {
+	case "ping":
+	case "add":
+		if old, ok := a.keys[req.Vault]; ok {
+			zero(old.key)
+		}
+		a.keys[req.Vault] = agentKey{req.Salt, req.Key, time.Now().Add(req.TTL)}
+	case "get":
+		if k, ok := a.keys[req.Vault]; ok && string(k.salt) == string(req.Salt) {
+			resp.Key = append([]byte(nil), k.key...)
+		}
+	case "lock":
+		a.clearLocked()
+		defer a.stop.Do(func() { close(a.done) })
+	default:
+		resp.Error = fmt.Sprintf("unknown agent request %q", req.Op)
+	}
+	a.lock.Unlock()
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// expire forgets the keys that expired by now, and returns how many are left.
+func (a *agent) expire(now time.Time) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for path, k := range a.keys {
+		if now.After(k.expires) {
+			zero(k.key)
+			delete(a.keys, path)
+		}
+	}
+	return len(a.keys)
+}
+
+func (a *agent) clear() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.clearLocked()
+}
+
+// clearLocked zeroes and forgets every key. The caller must hold the lock.
+func (a *agent) clearLocked() {
+	for path, k := range a.keys {
+		zero(k.key)
+		delete(a.keys, path)
+	}
+}
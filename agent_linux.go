@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// checkPeer refuses conn unless the process at the other end is run by the
+// user, checked with SO_PEERCRED.
+func checkPeer(conn net.Conn) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errAgentPeer
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return err
+	}
+	if credErr != nil {
+		return credErr
+	}
+	if int(cred.Uid) != os.Getuid() {
+		return errAgentPeer
+	}
+	return nil
+}
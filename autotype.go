@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const defaultTypeDelay = 2 * time.Second
+
+var errNoAutotype = errors.New("no autotype tool found, install xdotool, ydotool or wtype")
+
+// typeCmd returns a command that types its standard input into the focused
+// window. The secret is never put on the command line, where other users
+// could see it.
+func typeCmd() (*exec.Cmd, error) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" && runtime.GOOS != "openbsd" && runtime.GOOS != "netbsd" {
+		return nil, errNoAutotype
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wtype"); err == nil {
+			return exec.Command("wtype", "-"), nil
+		}
+		if _, err := exec.LookPath("ydotool"); err == nil {
+			return exec.Command("ydotool", "type", "--file", "-"), nil
+		}
+	}
+	if os.Getenv("DISPLAY") != "" {
+		if _, err := exec.LookPath("xdotool"); err == nil {
+			return exec.Command("xdotool", "type", "--clearmodifiers", "--file", "-"), nil
+		}
+	}
+	if _, err := exec.LookPath("ydotool"); err == nil {
+		return exec.Command("ydotool", "type", "--file", "-"), nil
+	}
+	return nil, errNoAutotype
+}
+
+// typeSecret types secret, described by what, into the window focused after
+// delay, so that the user has time to focus the field it belongs in.
+func typeSecret(what, secret string, delay time.Duration) error {
+	cmd, err := typeCmd()
+	if err != nil {
+		return err
+	}
+	info("typing %s in %s, focus the field to fill", what, delay)
+	time.Sleep(delay)
+	cmd.Stdin, cmd.Stderr = strings.NewReader(secret), os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pwnedRangeURL is the Have I Been Pwned range API, which is sent only the
+// first 5 hex digits of a password's SHA-1 hash and returns the suffixes of
+// every breached password hash with that prefix, so the password itself
+// never leaves the machine
+var pwnedRangeURL = "https://api.pwnedpasswords.com/range/"
+
+// breachCount returns how many times pswd has been seen in data breaches,
+// according to Have I Been Pwned.
+func breachCount(pswd string) (int, error) {
+	hash := fmt.Sprintf("%X", sha1.Sum([]byte(pswd)))
+	prefix, suffix := hash[:5], hash[5:]
+	req, err := http.NewRequest(http.MethodGet, pwnedRangeURL+prefix, nil)
+	if err != nil {
+		return 0, err
+	}
+	// padding hides how many suffixes were returned from eavesdroppers
+	req.Header.Set("Add-Padding", "true")
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("breach check: %s", resp.Status)
+	}
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		parts := strings.SplitN(strings.TrimSpace(sc.Text()), ":", 2)
+		if len(parts) == 2 && parts[0] == suffix {
+			return strconv.Atoi(parts[1])
+		}
+	}
+	return 0, sc.Err()
+}
+
+// warnBreached warns if pswd has been seen in data breaches. The check fails
+// open: if it can't be made, that is only warned about too.
+func warnBreached(pswd string) {
+	n, err := breachCount(pswd)
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "warning: couldn't check for breaches: %v\n", err)
+	case n > 0:
+		fmt.Fprintf(os.Stderr, "warning: password has appeared in %d data breaches, choose another\n", n)
+	}
+}
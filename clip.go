@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const defaultClipTimeout = 45 * time.Second
+
+var errNoClipboard = errors.New("no clipboard utility found, install xclip, xsel or wl-clipboard")
+
+// copyCmd returns a command that copies its standard input to the system
+// clipboard.
+func copyCmd() (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("pbcopy"), nil
+	case "windows":
+		return exec.Command("clip"), nil
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wl-copy"); err == nil {
+			return exec.Command("wl-copy"), nil
+		}
+	}
+	if _, err := exec.LookPath("xclip"); err == nil {
+		return exec.Command("xclip", "-selection", "clipboard"), nil
+	}
+	if _, err := exec.LookPath("xsel"); err == nil {
+		return exec.Command("xsel", "--clipboard", "--input"), nil
+	}
+	return nil, errNoClipboard
+}
+
+// pasteCmd returns a command that prints the contents of the system
+// clipboard.
+func pasteCmd() (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("pbpaste"), nil
+	case "windows":
+		return exec.Command("powershell", "-NoProfile", "-Command", "Get-Clipboard"), nil
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wl-paste"); err == nil {
+			return exec.Command("wl-paste", "--no-newline"), nil
+		}
+	}
+	if _, err := exec.LookPath("xclip"); err == nil {
+		return exec.Command("xclip", "-selection", "clipboard", "-o"), nil
+	}
+	if _, err := exec.LookPath("xsel"); err == nil {
+		return exec.Command("xsel", "--clipboard", "--output"), nil
+	}
+	return nil, errNoClipboard
+}
+
+func copyToClipboard(s string) error {
+	cmd, err := copyCmd()
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = strings.NewReader(s)
+	return cmd.Run()
+}
+
+// clipSecret copies secret, described by what, to the clipboard and, unless
+// timeout isn't positive, clears the clipboard again after it. The command
+// waits for that once it has finished with the vault.
+func clipSecret(what, secret string, timeout time.Duration) error {
+	if err := copyToClipboard(secret); err != nil {
+		return err
+	}
+	if timeout <= 0 {
+		info("copied %s to clipboard", what)
+		return nil
+	}
+	info("copied %s to clipboard, clearing in %s", what, timeout)
+	afterRun = append(afterRun, func() error { return clearClipboardAfter(timeout, secret) })
+	return nil
+}
+
+func readClipboard() (string, error) {
+	cmd, err := pasteCmd()
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// clearClipboardAfter waits for d and then empties the clipboard, unless it
+// no longer holds secret because something else has been copied meanwhile.
+// If the clipboard can't be read it is emptied anyway.
+func clearClipboardAfter(d time.Duration, secret string) error {
+	time.Sleep(d)
+	if current, err := readClipboard(); err == nil && strings.TrimRight(current, "\r\n") != strings.TrimRight(secret, "\r\n") {
+		info("clipboard changed, not clearing it")
+		return nil
+	}
+	return copyToClipboard("")
+}
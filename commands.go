@@ -0,0 +1,1475 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/patrickmcnamara/portunus/vault"
+)
+
+// command is a portunus subcommand
+type command struct {
+	name string
+	args string // positional arguments, for usage
+	help string // one line description, for usage
+
+	vault   bool // needs the vault opened
+	mutates bool // modifies the vault file
+	noShell bool // can't be run in the shell, which holds the vault open
+
+	// mutatesIf, if set, reports whether a command that mutates does so with
+	// the parsed flags and positional arguments, as history only does with
+	// -restore
+	mutatesIf func(fs *flag.FlagSet, args []string) bool
+
+	// setup defines the flags of the command on fs and returns a function that
+	// runs it with the remaining positional arguments
+	setup func(fs *flag.FlagSet) func(vlt *vault.Vault, args []string) error
+}
+
+// commands are the portunus subcommands, in the order they are listed by help
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "vlt", help: "create a new vault", mutates: true, noShell: true, setup: cmdVlt},
+		{name: "where", help: "print the path of the vault in use, without opening it", setup: cmdWhere},
+		{name: "vaults", help: "list named vaults", setup: cmdVaults},
+		{name: "passwd", help: "change the master password", vault: true, mutates: true, setup: cmdPasswd},
+		{name: "calibrate", help: "tune how long deriving the vault key takes on this machine", vault: true, mutates: true, setup: cmdCalibrate},
+		{name: "set", args: "NAME", help: "store a password typed on the terminal or piped to stdin", vault: true, mutates: true, setup: cmdSet},
+		{name: "batch", help: "store many NAME<TAB>PASSWORD lines from stdin at once", vault: true, mutates: true, setup: cmdBatch},
+		{name: "new", args: "NAME", help: "store a generated password", vault: true, mutates: true, setup: cmdNew},
+		{name: "note", args: "NAME", help: "store a secure note typed on the terminal or piped to stdin", vault: true, mutates: true, setup: cmdNote},
+		{name: "edit", args: "NAME", help: "edit an entry in $EDITOR", vault: true, mutates: true, setup: cmdEdit},
+		{name: "get", args: "NAME", help: "print a password", vault: true, setup: cmdGet},
+		{name: "clip-clear", help: "empty the clipboard", setup: cmdClipClear},
+		{name: "otp", args: "NAME", help: "print the current TOTP code", vault: true, setup: cmdOTP},
+		{name: "alias", args: "[ALIAS TARGET]", help: "make ALIAS another name for the entry TARGET, or list aliases", vault: true, mutates: true, mutatesIf: hasArgs, setup: cmdAlias},
+		{name: "rem", args: "NAME...", help: "remove entries, or every entry with a tag", vault: true, mutates: true, setup: cmdRem},
+		{name: "purge", help: "remove every entry", vault: true, mutates: true, setup: cmdPurge},
+		{name: "history", args: "NAME", help: "list or restore previous passwords", vault: true, mutates: true, mutatesIf: flagGiven("restore"), setup: cmdHistory},
+		{name: "rename", args: "OLD NEW", help: "rename an entry", vault: true, mutates: true, setup: cmdRename},
+		{name: "lst", args: "[PREFIX]", help: "list entry names", vault: true, setup: cmdLst},
+		{name: "count", help: "print the number of entries", vault: true, setup: cmdCount},
+		{name: "find", args: "QUERY", help: "list entry names containing a string", vault: true, setup: cmdFind},
+		{name: "gen", help: "print a generated password", setup: cmdGen},
+		{name: "stale", help: "list entries overdue for a password change", vault: true, setup: cmdStale},
+		{name: "verify", help: "check that the vault opens and its entries are sound", vault: true, setup: cmdVerify},
+		{name: "audit", help: "report short, reused and weak passwords", vault: true, setup: cmdAudit},
+		{name: "export", args: "FILE", help: "write every entry to a file", vault: true, setup: cmdExport},
+		{name: "diff", args: "FILE", help: "list the entries added, removed and changed in an exported file", vault: true, setup: cmdDiff},
+		{name: "import", args: "FILE", help: "add the entries from an exported file", vault: true, mutates: true, setup: cmdImport},
+		{name: "import-csv", args: "FILE", help: "add the entries from a CSV file", vault: true, mutates: true, setup: cmdImportCSV},
+		{name: "unlock", help: "keep the vault key in the agent so commands don't ask for the master password", vault: true, setup: cmdUnlock},
+		{name: "lock", help: "make the agent forget every vault key", setup: cmdLock},
+		{name: "agent", help: "run the agent that unlock starts, in the foreground", noShell: true, setup: cmdAgent},
+		{name: "restore", help: "replace the vault with its latest backup", mutates: true, noShell: true, setup: cmdRestore},
+		{name: "shell", help: "run commands on the vault, entering the master password once", vault: true, noShell: true, setup: cmdShell},
+		{name: "version", help: "print the version", setup: cmdVersion},
+		{name: "completion", args: "SHELL", help: "print a bash, zsh or fish completion script", setup: cmdCompletion},
+		{name: "help", args: "[COMMAND]", help: "print usage", setup: cmdHelp},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// flagGiven returns a mutatesIf function for a command that only mutates if
+// the named flag isn't left at its default.
+func flagGiven(name string) func(*flag.FlagSet, []string) bool {
+	return func(fs *flag.FlagSet, _ []string) bool {
+		f := fs.Lookup(name)
+		return f.Value.String() != f.DefValue
+	}
+}
+
+// hasArgs is a mutatesIf function for a command that only mutates if it is
+// given positional arguments.
+func hasArgs(_ *flag.FlagSet, args []string) bool {
+	return len(args) > 0
+}
+
+// run runs the subcommand name with args, locking and opening the vault if
+// the subcommand needs it.
+func run(name string, args []string) error {
+	return runIn(nil, name, args)
+}
+
+// afterRun holds work for after the running subcommand has unlocked and
+// closed the vault, like clearing the clipboard, so that other commands
+// aren't locked out of the vault meanwhile.
+var afterRun []func() error
+
+// background is the afterRun work of commands run in the shell, which is
+// done in the background so that the shell can go on, and waited for when it
+// exits.
+var background sync.WaitGroup
+
+// runIn runs the subcommand name with args like run but, if vlt isn't nil,
+// on vlt, which the caller has already locked and opened.
+func runIn(vlt *vault.Vault, name string, args []string) error {
+	afterRun = nil
+	err := runVault(vlt, name, args)
+	pending := afterRun
+	afterRun = nil
+	if err != nil {
+		return err
+	}
+	for _, f := range pending {
+		if vlt != nil {
+			background.Add(1)
+			go func(f func() error) {
+				defer background.Done()
+				if err := f(); err != nil {
+					printError(err)
+				}
+			}(f)
+			continue
+		}
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runVault runs the subcommand for runIn, which runs afterRun once the vault
+// has been unlocked and closed again.
+func runVault(vlt *vault.Vault, name string, args []string) error {
+	cmd, ok := findCommand(name)
+	if !ok {
+		return errBadArgs
+	}
+	if vlt != nil && cmd.noShell {
+		return errNoShell
+	}
+	fs := newFlagSet(cmd.name)
+	runCmd := cmd.setup(fs)
+	args, err := parseFlags(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		printCommandUsage(cmd, fs)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if cmd.mutatesIf != nil {
+		cmd.mutates = cmd.mutatesIf(fs, args)
+	}
+	if cmd.mutates && readOnly() {
+		return errReadOnly
+	}
+	if cmd.mutates && vaultFile != stdioVault {
+		unlock, err := vault.Lock(vaultFile)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+	if cmd.mutates && vaultFile == stdioVault {
+		// stdout is kept for the changed vault, so what the command prints
+		// goes to stderr
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = vaultOut }()
+	}
+	if vlt != nil {
+		// the shell only locks the vault for commands that change it, so it
+		// picks up changes made meanwhile first
+		if err := reloadVault(vlt); err != nil {
+			return err
+		}
+		return runCmd(vlt, args)
+	}
+	if cmd.vault {
+		vlt, err = openVault()
+		if err != nil {
+			return err
+		}
+		defer vlt.Close()
+	}
+	return runCmd(vlt, args)
+}
+
+func printUsage() {
+	fmt.Println("usage: portunus [flags] COMMAND [flags] [ARGS]")
+	fmt.Println()
+	fmt.Println("commands:")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.help)
+	}
+	tw.Flush()
+	fmt.Println()
+	fmt.Println("flags:")
+	fs := newFlagSet("portunus")
+	globalFlags(fs)
+	fs.SetOutput(os.Stdout)
+	fs.PrintDefaults()
+	fmt.Println()
+	fmt.Println("Run 'portunus help COMMAND' for the flags of a command.")
+}
+
+func printCommandUsage(cmd command, fs *flag.FlagSet) {
+	fmt.Printf("usage: portunus %s [flags] %s\n", cmd.name, cmd.args)
+	fmt.Println()
+	fmt.Println(cmd.help)
+	var hasFlags bool
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Println()
+		fmt.Println("flags:")
+		fs.SetOutput(os.Stdout)
+		fs.PrintDefaults()
+	}
+}
+
+func cmdVlt(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(_ *vault.Vault, args []string) error {
+		// saveVault isn't used, so -dry-run is handled here
+		if dryRun {
+			fmt.Fprintf(os.Stderr, "would create vault at %s\n", vaultFile)
+			fmt.Fprintln(os.Stderr, "dry run, vault not created")
+			return nil
+		}
+		_, err := vault.CreateStore(vaultStore(), func() (string, error) {
+			return newMasterPassword(readMasterPassword)
+		})
+		if err != nil {
+			return err
+		}
+		warnPerms(vaultFile)
+		info("created vault at %s", vaultFile)
+		return nil
+	}
+}
+
+func cmdVaults(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(_ *vault.Vault, args []string) error {
+		names, err := vaultNames(os.Getenv)
+		if err != nil {
+			return err
+		}
+		return printNames(names, jsonOutput)
+	}
+}
+
+func cmdWhere(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(_ *vault.Vault, args []string) error {
+		if vaultFile == stdioVault {
+			if jsonOutput {
+				return printJSON(struct {
+					Path  string `json:"path"`
+					Stdio bool   `json:"stdio"`
+				}{stdioVault, true})
+			}
+			fmt.Println(stdioVault)
+			info("the vault is read from stdin and written to stdout")
+			return nil
+		}
+		path, err := filepath.Abs(vaultFile)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stat(path)
+		exists := err == nil
+		if jsonOutput {
+			return printJSON(struct {
+				Path   string `json:"path"`
+				Exists bool   `json:"exists"`
+			}{path, exists})
+		}
+		fmt.Println(path)
+		if !exists {
+			info("no vault there yet, run 'portunus vlt' to create one")
+		}
+		return nil
+	}
+}
+
+func cmdPasswd(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(vlt *vault.Vault, args []string) error {
+		// PORTUNUS_PASSWORD only ever gives the current master password
+		pswd, err := newMasterPassword(promptPassword)
+		if err != nil {
+			return err
+		}
+		vlt.Rekey(pswd)
+		return saveVault(vlt, "changed master password")
+	}
+}
+
+func cmdCalibrate(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	target := fs.Duration("target", 500*time.Millisecond, "aim for deriving the key to take this long")
+	memory := fs.Uint("memory", uint(vault.DefaultKDF.Memory/1024), "MiB of memory to derive the key with")
+	return func(vlt *vault.Vault, args []string) error {
+		// CalibrateKDF checks the memory, once it is in KiB without overflowing
+		if *memory > math.MaxUint32/1024 {
+			return vault.ErrBadKDF
+		}
+		kdf, took, err := vault.CalibrateKDF(*target, uint32(*memory*1024))
+		if err != nil {
+			return err
+		}
+		info("%d iterations with %d MiB took %v", kdf.Time, kdf.Memory/1024, took.Round(time.Millisecond))
+		// the key is derived again from the master password, so check it
+		pswd, err := readMasterPassword("master password: ")
+		if err != nil {
+			return err
+		}
+		if !vlt.CheckPassword(pswd) {
+			return errWrongMaster
+		}
+		if err := vlt.RekeyKDF(pswd, kdf); err != nil {
+			return err
+		}
+		return saveVault(vlt, "vault key now derived with %d iterations of %d MiB", kdf.Time, kdf.Memory/1024)
+	}
+}
+
+func cmdSet(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	meta := metaFlags(fs)
+	totpSecret := fs.Bool("totp", false, "read and store a base32 TOTP secret instead of the password")
+	file := fs.String("file", "", "store the contents of this file instead of the password")
+	maxSize := fs.Int64("max-size", defaultMaxFileSize, "largest file in bytes that -file stores")
+	force := forceFlag(fs)
+	strength := strengthFlag(fs)
+	allowReuse := fs.Bool("allow-reuse", false, "don't warn if another entry has the same password")
+	checkBreaches := fs.Bool("check-breaches", false, "warn if the password has appeared in data breaches, sending the first 5 characters of its SHA-1 hash to Have I Been Pwned")
+	generated := fs.Bool("generate", false, "store a generated password instead of reading one, like 'new'")
+	generator := generatorFlags(fs)
+	show := fs.Bool("print", false, "also print the password generated with -generate")
+	fs.BoolVar(show, "p", false, "shorthand for -print")
+	clip, clipTimeout := clipFlags(fs)
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 1 {
+			return errBadArgsSet
+		}
+		name := args[0]
+		if err := vault.CheckName(name); err != nil {
+			return err
+		}
+		if *generated && (*totpSecret || *file != "") {
+			return errSetGenerate
+		}
+		e, _ := vlt.Get(name)
+		var generate func() string
+		var bits float64
+		if *generated {
+			if err := applyPolicy(fs, e); err != nil {
+				return err
+			}
+			var err error
+			if generate, bits, err = generator(); err != nil {
+				return err
+			}
+			meta.Policy = generatorArgs(fs)
+		}
+		var exists bool
+		switch {
+		case *totpSecret:
+			exists = e.TOTP != ""
+		case *file != "":
+			exists = e.File != nil
+		default:
+			exists = e.Password != ""
+		}
+		if exists {
+			if err := confirmOverwrite(name, *force); err != nil {
+				return err
+			}
+		}
+		var pswd string
+		switch {
+		case *totpSecret:
+			secret, err := promptPassword("TOTP secret: ")
+			if err != nil {
+				return err
+			}
+			if err := vlt.SetTOTP(name, secret); err != nil {
+				return err
+			}
+		case *file != "":
+			data, err := readFileMax(*file, *maxSize)
+			if err != nil {
+				return err
+			}
+			if err := vlt.SetFile(name, data); err != nil {
+				return err
+			}
+		case *generated:
+			pswd = generate()
+			if _, err := vlt.Set(name, pswd, *meta); err != nil {
+				return err
+			}
+			if *strength {
+				printStrength(bits)
+			}
+		default:
+			pswd, err := readNewPassword()
+			if err != nil {
+				return err
+			}
+			if names := vlt.UsedBy(pswd, name); len(names) > 0 && !*allowReuse {
+				fmt.Fprintf(os.Stderr, "warning: password is already used by: %s\n", strings.Join(names, ", "))
+			}
+			if *checkBreaches {
+				warnBreached(pswd)
+			}
+			changed, err := vlt.Set(name, pswd, *meta)
+			if err != nil {
+				return err
+			}
+			if *strength {
+				printStrength(vault.EstimateEntropy(pswd))
+			}
+			if !changed {
+				info("'%s' unchanged", name)
+				return nil
+			}
+		}
+		if err := saveVault(vlt, "stored '%s'", name); err != nil {
+			return err
+		}
+		switch {
+		case *generated && *clip:
+			return clipSecret(fmt.Sprintf("'%s'", name), pswd, *clipTimeout)
+		case *generated && *show:
+			fmt.Println(pswd)
+		}
+		return nil
+	}
+}
+
+func cmdBatch(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	force := fs.Bool("force", false, "overwrite existing passwords")
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 0 {
+			return errBadArgsBat
+		}
+		pswds := make(map[string]string)
+		for line := 1; ; line++ {
+			text, err := stdin.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return err
+			}
+			if text = strings.TrimRight(text, "\r\n"); text != "" {
+				name, pswd, err := parseBatchLine(text)
+				if err != nil {
+					return fmt.Errorf("line %d: %w", line, err)
+				}
+				if e, _ := vlt.Get(name); e.Password != "" && !*force {
+					return fmt.Errorf("line %d: %w", line, errNotOverwritten)
+				}
+				pswds[name] = pswd
+			}
+			if err == io.EOF {
+				break
+			}
+		}
+		if err := vlt.SetAll(pswds); err != nil {
+			return err
+		}
+		return saveVault(vlt, "stored %d entries", len(pswds))
+	}
+}
+
+// printTree prints sorted names as a tree, each '/' separated part indented
+// under the parts before it, which end in '/'.
+func printTree(names []string) {
+	var prev []string
+	for _, name := range names {
+		parts := strings.Split(name, "/")
+		dirs := parts[:len(parts)-1]
+		shared := 0
+		for shared < len(dirs) && shared < len(prev) && dirs[shared] == prev[shared] {
+			shared++
+		}
+		for i := shared; i < len(dirs); i++ {
+			fmt.Printf("%s%s/\n", strings.Repeat("  ", i), dirs[i])
+		}
+		fmt.Printf("%s%s\n", strings.Repeat("  ", len(dirs)), parts[len(parts)-1])
+		prev = dirs
+	}
+}
+
+// entryFields are the fields of entries that 'get -field' prints
+var entryFields = map[string]func(vault.Entry) string{
+	"password": func(e vault.Entry) string { return e.Password },
+	"username": func(e vault.Entry) string { return e.Username },
+	"url":      func(e vault.Entry) string { return e.URL },
+	"notes":    func(e vault.Entry) string { return e.Notes },
+}
+
+// parseBatchLine splits a NAME<TAB>PASSWORD line.
+func parseBatchLine(line string) (name, pswd string, err error) {
+	i := strings.IndexByte(line, '\t')
+	if i < 0 {
+		return "", "", errBatchLine
+	}
+	name, pswd = line[:i], line[i+1:]
+	if err := vault.CheckName(name); err != nil {
+		return "", "", err
+	}
+	if pswd == "" {
+		return "", "", errNoPassword
+	}
+	return name, pswd, nil
+}
+
+func cmdNew(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	generator := generatorFlags(fs)
+	meta := metaFlags(fs)
+	force := forceFlag(fs)
+	strength := strengthFlag(fs)
+	show := fs.Bool("print", false, "also print the generated password")
+	fs.BoolVar(show, "p", false, "shorthand for -print")
+	clip, clipTimeout := clipFlags(fs)
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 1 {
+			return errBadArgsNew
+		}
+		name := args[0]
+		e, _ := vlt.Get(name)
+		if err := applyPolicy(fs, e); err != nil {
+			return err
+		}
+		generate, bits, err := generator()
+		if err != nil {
+			return err
+		}
+		if e.Password != "" {
+			if err := confirmOverwrite(name, *force); err != nil {
+				return err
+			}
+		}
+		meta.Policy = generatorArgs(fs)
+		pswd := generate()
+		if _, err := vlt.Set(name, pswd, *meta); err != nil {
+			return err
+		}
+		if *strength {
+			printStrength(bits)
+		}
+		if err := saveVault(vlt, "stored '%s'", name); err != nil {
+			return err
+		}
+		if *clip {
+			return clipSecret(fmt.Sprintf("'%s'", name), pswd, *clipTimeout)
+		}
+		if *show {
+			fmt.Println(pswd)
+		}
+		return nil
+	}
+}
+
+func cmdNote(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	force := forceFlag(fs)
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 1 {
+			return errBadArgsNte
+		}
+		name := args[0]
+		if err := vault.CheckName(name); err != nil {
+			return err
+		}
+		if e, _ := vlt.Get(name); e.Notes != "" {
+			if err := confirmOverwrite(name, *force); err != nil {
+				return err
+			}
+		}
+		notes, err := readNotes()
+		if err != nil {
+			return err
+		}
+		if err := vlt.SetNotes(name, notes); err != nil {
+			return err
+		}
+		return saveVault(vlt, "stored '%s'", name)
+	}
+}
+
+func cmdEdit(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 1 {
+			return errBadArgsEdt
+		}
+		name, e, err := getEntry(vlt, args[0])
+		if err != nil {
+			return err
+		}
+		e, changed, err := editEntry(e)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			info("'%s' unchanged", name)
+			return nil
+		}
+		if err := vlt.Edit(name, e); err != nil {
+			return err
+		}
+		return saveVault(vlt, "edited '%s'", name)
+	}
+}
+
+func cmdGet(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	clip, clipTimeout := clipFlags(fs)
+	file := fs.String("file", "", "write the file stored with 'set -file' to this path")
+	field := fs.String("field", "", "print or copy only this field: password, username, url or notes")
+	noNewline := fs.Bool("no-newline", false, "don't print a newline after the password")
+	fs.BoolVar(noNewline, "n", false, "shorthand for -no-newline")
+	output := outputFlag(fs)
+	force := fs.Bool("force", false, "overwrite an existing file given with -file or -output")
+	autotype := fs.Bool("type", false, "type the password into the focused window instead of printing it")
+	typeDelay := fs.Duration("type-delay", defaultTypeDelay, "wait this long before typing, to focus the field")
+	all := fs.Bool("all", false, "print the username, password, URL and current TOTP code together")
+	// printValue prints s, described by what, or writes it to -output
+	printValue := func(what, s string) error {
+		if !*noNewline {
+			s += "\n"
+		}
+		if *output == "" {
+			fmt.Print(s)
+			return nil
+		}
+		if err := writeFile(*output, []byte(s), *force); err != nil {
+			return err
+		}
+		info("wrote %s to %s", what, *output)
+		return nil
+	}
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 1 {
+			return errBadArgsGet
+		}
+		value, ok := entryFields[*field]
+		if *field != "" && !ok {
+			return errBadField
+		}
+		if *autotype && (*clip || *output != "" || *file != "") {
+			return errTypeFlags
+		}
+		if *all && (*field != "" || *clip || *autotype || *output != "" || *file != "") {
+			return errAllFlags
+		}
+		name := args[0]
+		_, e, err := getEntry(vlt, name)
+		if err != nil {
+			return err
+		}
+		if *all {
+			return printLogin(name, e)
+		}
+		if *file != "" {
+			if e.File == nil {
+				return errNoFile
+			}
+			if err := writeFile(*file, e.File, *force); err != nil {
+				return err
+			}
+			info("wrote '%s' to %s", name, *file)
+			return nil
+		}
+		if *field != "" {
+			v := value(e)
+			if v == "" {
+				return fmt.Errorf("%w '%s'", errNoField, *field)
+			}
+			if *clip {
+				return clipSecret(fmt.Sprintf("%s of '%s'", *field, name), v, *clipTimeout)
+			}
+			if *autotype {
+				return typeSecret(fmt.Sprintf("%s of '%s'", *field, name), v, *typeDelay)
+			}
+			if jsonOutput && *output == "" {
+				return printJSON(v)
+			}
+			return printValue(fmt.Sprintf("%s of '%s'", *field, name), v)
+		}
+		// notes are the secret of entries without a password
+		secret := e.Password
+		if secret == "" {
+			secret = e.Notes
+		}
+		if *clip {
+			return clipSecret(fmt.Sprintf("'%s'", name), secret, *clipTimeout)
+		}
+		if *autotype {
+			return typeSecret(fmt.Sprintf("'%s'", name), secret, *typeDelay)
+		}
+		if jsonOutput && *output == "" {
+			return printJSON(entryJSON{
+				Name:     name,
+				Password: e.Password,
+				Username: e.Username,
+				URL:      e.URL,
+				Notes:    e.Notes,
+				Tags:     e.Tags,
+				Created:  e.Created,
+				Modified: e.Modified,
+			})
+		}
+		return printValue(fmt.Sprintf("'%s'", name), secret)
+	}
+}
+
+// entryJSON is what 'get -json' prints: the current fields of an entry,
+// leaving out its previous passwords, TOTP secret and file
+type entryJSON struct {
+	Name     string    `json:"name"`
+	Password string    `json:"password"`
+	Username string    `json:"username,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	Notes    string    `json:"notes,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+}
+
+// login is what 'get -all' prints: everything needed to log in with an entry
+type login struct {
+	Name     string    `json:"name"`
+	Username string    `json:"username,omitempty"`
+	Password string    `json:"password,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	OTP      *loginOTP `json:"otp,omitempty"`
+}
+
+// loginOTP is a TOTP code and the seconds it is valid for
+type loginOTP struct {
+	Code      string `json:"code"`
+	Remaining int    `json:"remaining"`
+}
+
+// printLogin prints the username, password, URL and current TOTP code of e
+// to stdout, as a JSON object with -json or else as "field: value" lines.
+func printLogin(name string, e vault.Entry) error {
+	l := login{Name: name, Username: e.Username, Password: e.Password, URL: e.URL}
+	if e.TOTP != "" {
+		code, left, err := vault.TOTP(e.TOTP, time.Now())
+		if err != nil {
+			return err
+		}
+		l.OTP = &loginOTP{code, int(left.Seconds())}
+	}
+	if jsonOutput {
+		return printJSON(l)
+	}
+	for _, f := range []struct{ name, value string }{
+		{"username", l.Username},
+		{"password", l.Password},
+		{"url", l.URL},
+	} {
+		if f.value != "" {
+			fmt.Printf("%s: %s\n", f.name, f.value)
+		}
+	}
+	if l.OTP != nil {
+		fmt.Printf("otp: %s\n", l.OTP.Code)
+	}
+	return nil
+}
+
+func cmdClipClear(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(_ *vault.Vault, args []string) error {
+		if err := copyToClipboard(""); err != nil {
+			return err
+		}
+		info("cleared clipboard")
+		return nil
+	}
+}
+
+func cmdOTP(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	skew := fs.Duration("skew", 0, "add this to the local clock, to correct for a known clock skew")
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 1 {
+			return errBadArgsOTP
+		}
+		name := args[0]
+		_, e, err := getEntry(vlt, name)
+		if err != nil {
+			return err
+		}
+		if e.TOTP == "" {
+			return vault.ErrTOTPMissing
+		}
+		code, left, err := vault.TOTP(e.TOTP, time.Now().Add(*skew))
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(struct {
+				Name      string `json:"name"`
+				Code      string `json:"code"`
+				Remaining int    `json:"remaining"`
+			}{name, code, int(left.Seconds())})
+		}
+		fmt.Println(code)
+		info("valid for %ds", int(left.Seconds()))
+		return nil
+	}
+}
+
+func cmdRem(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	strict := fs.Bool("strict", false, "remove nothing if any of the names isn't in the vault")
+	tag := fs.String("tag", "", "remove every entry with this tag instead")
+	yes := fs.Bool("yes", false, "remove the entries with -tag without asking")
+	fs.BoolVar(yes, "y", false, "shorthand for -yes")
+	return func(vlt *vault.Vault, args []string) error {
+		if *tag != "" {
+			if len(args) != 0 {
+				return errBadArgsRem
+			}
+			return removeTagged(vlt, *tag, *yes)
+		}
+		if len(args) == 0 {
+			return errBadArgsRem
+		}
+		var removed, aliases, missing []string
+		for _, name := range args {
+			if _, err := vlt.Get(name); err != nil {
+				missing = append(missing, fmt.Sprintf("'%s'", name))
+			}
+		}
+		if len(missing) == 0 || !*strict {
+			for _, name := range args {
+				to := vlt.AliasesTo(name)
+				if vlt.Remove(name) == nil {
+					removed = append(removed, fmt.Sprintf("'%s'", name))
+					aliases = append(aliases, to...)
+				}
+			}
+		}
+		if len(removed) > 0 {
+			if err := saveVault(vlt, "removed %s%s", strings.Join(removed, ", "), aliasesNote(aliases)); err != nil {
+				return err
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%w: %s", vault.ErrNoSuchValue, strings.Join(missing, ", "))
+		}
+		return nil
+	}
+}
+
+// removeTagged removes every entry tagged tag, after confirming it unless yes
+// is set.
+func removeTagged(vlt *vault.Vault, tag string, yes bool) error {
+	var names []string
+	for name, e := range vlt.Entries() {
+		if e.HasTag(tag) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("%w: no entries tagged '%s'", vault.ErrNoSuchValue, tag)
+	}
+	sort.Strings(names)
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("'%s'", name)
+	}
+	if !yes && !confirm(fmt.Sprintf("remove %s?", strings.Join(quoted, ", "))) {
+		return errNotRemoved
+	}
+	var aliases []string
+	for _, name := range names {
+		to := vlt.AliasesTo(name)
+		if err := vlt.Remove(name); err != nil {
+			return err
+		}
+		aliases = append(aliases, to...)
+	}
+	return saveVault(vlt, "removed %s%s", strings.Join(quoted, ", "), aliasesNote(aliases))
+}
+
+// aliasesNote describes the aliases removed along with the entries they led
+// to, for the message confirming it.
+func aliasesNote(aliases []string) string {
+	if len(aliases) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(aliases))
+	for i, alias := range aliases {
+		quoted[i] = fmt.Sprintf("'%s'", alias)
+	}
+	return fmt.Sprintf(" and the aliases %s", strings.Join(quoted, ", "))
+}
+
+func cmdAlias(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(vlt *vault.Vault, args []string) error {
+		switch len(args) {
+		case 0:
+			aliases := vlt.Aliases()
+			if jsonOutput {
+				return printJSON(aliases)
+			}
+			names := make([]string, 0, len(aliases))
+			for name := range aliases {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("%s -> %s\n", name, aliases[name])
+			}
+			return nil
+		case 2:
+			if err := vlt.SetAlias(args[0], args[1]); err != nil {
+				return err
+			}
+			return saveVault(vlt, "made '%s' an alias of '%s'", args[0], args[1])
+		}
+		return errBadArgsAls
+	}
+}
+
+func cmdPurge(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	yes := fs.Bool("yes", false, "remove every entry without asking")
+	fs.BoolVar(yes, "y", false, "shorthand for -yes")
+	return func(vlt *vault.Vault, args []string) error {
+		if !*yes && !confirm(fmt.Sprintf("remove all %d entries?", vlt.Len())) {
+			return errNotPurged
+		}
+		n := vlt.Clear()
+		return saveVault(vlt, "removed %d entries", n)
+	}
+}
+
+func cmdHistory(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	restore := fs.Int("restore", 0, "make the Nth previous password, as listed, the password again")
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 1 {
+			return errBadArgsHst
+		}
+		name, e, err := getEntry(vlt, args[0])
+		if err != nil {
+			return err
+		}
+		if *restore != 0 {
+			if err := vlt.Rollback(name, *restore-1); err != nil {
+				return err
+			}
+			return saveVault(vlt, "restored previous password %d of '%s'", *restore, name)
+		}
+		if jsonOutput {
+			history := e.History
+			if history == nil {
+				history = []vault.OldPassword{}
+			}
+			return printJSON(history)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for i, old := range e.History {
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", i+1, formatTime(old.Replaced), old.Password)
+		}
+		return tw.Flush()
+	}
+}
+
+func cmdRename(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 2 {
+			return errBadArgsRen
+		}
+		oldName, newName := args[0], args[1]
+		if err := vlt.Rename(oldName, newName); err != nil {
+			return err
+		}
+		return saveVault(vlt, "renamed '%s' to '%s'", oldName, newName)
+	}
+}
+
+func cmdLst(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	show := fs.Bool("show", false, "also print passwords")
+	long := fs.Bool("long", false, "also print when entries were created and modified, their usernames and tags")
+	var tags stringsFlag
+	fs.Var(&tags, "tag", "only list entries with this `tag`, can be repeated")
+	tree := fs.Bool("tree", false, "print names as a tree of their '/' separated parts")
+	var since sinceFlag
+	fs.Var(&since, "since", "only list entries modified since this time or this long ago, e.g. 7d")
+	limit := fs.Int("limit", 0, "list at most this many entries, 0 for all")
+	offset := fs.Int("offset", 0, "skip this many entries, in name order, before listing")
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) > 1 {
+			return errBadArgsLst
+		}
+		if *limit < 0 || *offset < 0 {
+			return errBadPage
+		}
+		var prefix string
+		if len(args) == 1 {
+			prefix = vault.NormName(args[0])
+		}
+		if *tree && (*show || *long || jsonOutput) {
+			return errTreeFlags
+		}
+		entries := vlt.Entries()
+		names := vlt.Find(func(name string) bool {
+			if !strings.HasPrefix(name, prefix) {
+				return false
+			}
+			// entries from older vaults have no modification time and never match
+			if t := time.Time(since); !t.IsZero() && entries[name].Modified.Before(t) {
+				return false
+			}
+			for _, tag := range tags {
+				if !entries[name].HasTag(tag) {
+					return false
+				}
+			}
+			return true
+		})
+		names = page(names, *offset, *limit)
+		if *tree {
+			printTree(names)
+			return nil
+		}
+		if !*show && !*long {
+			return printNames(names, jsonOutput)
+		}
+		if *show {
+			fmt.Fprintln(os.Stderr, "warning: printing passwords")
+		}
+		if jsonOutput && !*long {
+			pswds := make(map[string]string, len(names))
+			for _, name := range names {
+				pswds[name] = entries[name].Password
+			}
+			return printJSON(pswds)
+		}
+		if jsonOutput {
+			type longEntry struct {
+				Name     string    `json:"name"`
+				Created  time.Time `json:"created"`
+				Modified time.Time `json:"modified"`
+				Username string    `json:"username,omitempty"`
+				Tags     []string  `json:"tags,omitempty"`
+				Password string    `json:"password,omitempty"`
+			}
+			les := make([]longEntry, len(names))
+			for i, name := range names {
+				e := entries[name]
+				les[i] = longEntry{Name: name, Created: e.Created, Modified: e.Modified, Username: e.Username, Tags: e.Tags}
+				if *show {
+					les[i].Password = e.Password
+				}
+			}
+			return printJSON(les)
+		}
+		if !*long {
+			for _, name := range names {
+				fmt.Printf("%s: %s\n", name, entries[name].Password)
+			}
+			return nil
+		}
+		header := []string{"NAME", "CREATED", "MODIFIED", "USERNAME", "TAGS"}
+		if *show {
+			header = append(header, "PASSWORD")
+		}
+		rows := make([][]string, len(names))
+		for i, name := range names {
+			e := entries[name]
+			if e.Alias != "" {
+				name += " -> " + e.Alias
+			}
+			rows[i] = []string{name, formatTime(e.Created), formatTime(e.Modified), orDash(e.Username), orDash(strings.Join(e.Tags, ","))}
+			if *show {
+				rows[i] = append(rows[i], e.Password)
+			}
+		}
+		return printTable(header, rows)
+	}
+}
+
+// strengths rates passwords by their entropy in bits, weakest first
+var strengths = []struct {
+	bits   float64
+	rating string
+}{
+	{40, "weak"},
+	{60, "fair"},
+	{80, "strong"},
+	{math.Inf(1), "very strong"},
+}
+
+// printStrength prints a rating of a password with bits of entropy to stderr,
+// so that it is never mixed up with the password itself.
+func printStrength(bits float64) {
+	for _, s := range strengths {
+		if bits < s.bits {
+			fmt.Fprintf(os.Stderr, "strength: %s, %.1f bits of entropy\n", s.rating, bits)
+			return
+		}
+	}
+}
+
+// orDash returns s, or "-" to fill an empty table cell.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// page returns the names after the first offset, up to limit of them if
+// limit isn't 0.
+func page(names []string, offset, limit int) []string {
+	if offset > len(names) {
+		offset = len(names)
+	}
+	names = names[offset:]
+	if limit > 0 && limit < len(names) {
+		names = names[:limit]
+	}
+	return names
+}
+
+// formatTime formats t in local time, or as "-" if it is unknown.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func cmdCount(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(vlt *vault.Vault, args []string) error {
+		if jsonOutput {
+			return printJSON(struct {
+				Count int `json:"count"`
+			}{vlt.Len()})
+		}
+		fmt.Println(vlt.Len())
+		return nil
+	}
+}
+
+func cmdFind(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	useRegex := fs.Bool("regex", false, "treat the query as a regular expression")
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 1 {
+			return errBadArgsFnd
+		}
+		query := args[0]
+		var match func(string) bool
+		if *useRegex {
+			re, err := regexp.Compile("(?i)" + query)
+			if err != nil {
+				return err
+			}
+			match = re.MatchString
+		} else {
+			query = strings.ToLower(vault.NormName(query))
+			match = func(name string) bool {
+				return strings.Contains(strings.ToLower(name), query)
+			}
+		}
+		return printNames(vlt.Find(match), jsonOutput)
+	}
+}
+
+func cmdGen(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	generator := generatorFlags(fs)
+	strength := strengthFlag(fs)
+	fs.BoolVar(strength, "verbose", false, "same as -strength")
+	fs.BoolVar(strength, "v", false, "shorthand for -verbose")
+	count := fs.Int("count", 1, "print this many passwords, one per line")
+	return func(_ *vault.Vault, args []string) error {
+		if *count < 1 {
+			return errBadCount
+		}
+		generate, bits, err := generator()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < *count; i++ {
+			fmt.Println(generate())
+		}
+		if *strength {
+			printStrength(bits)
+		}
+		return nil
+	}
+}
+
+func cmdStale(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(vlt *vault.Vault, args []string) error {
+		return printNames(vlt.Stale(time.Now()), jsonOutput)
+	}
+}
+
+func cmdVerify(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(vlt *vault.Vault, args []string) error {
+		problems := vlt.Verify()
+		if jsonOutput {
+			if err := printJSON(problems); err != nil {
+				return err
+			}
+		} else {
+			for _, p := range problems {
+				fmt.Printf("%s: %s\n", p.Name, p.Problem)
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%w, %d found", errVerify, len(problems))
+		}
+		info("vault ok, %d entries", vlt.Len())
+		return nil
+	}
+}
+
+func cmdAudit(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	minLength := fs.Int("min-length", vault.DefaultAuditLength, "report passwords with fewer characters")
+	minEntropy := fs.Float64("min-entropy", vault.DefaultAuditEntropy, "report passwords with fewer estimated bits of entropy")
+	return func(vlt *vault.Vault, args []string) error {
+		rep := vlt.Audit(*minLength, *minEntropy)
+		if jsonOutput {
+			return printJSON(rep)
+		}
+		fmt.Print(rep)
+		return nil
+	}
+}
+
+func cmdExport(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	plain := fs.Bool("plain", false, "write unencrypted JSON")
+	output := outputFlag(fs)
+	force := fs.Bool("force", false, "overwrite an existing file")
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) == 1 && *output == "" {
+			*output = args[0]
+		} else if len(args) != 0 || *output == "" {
+			return errBadArgsExp
+		}
+		data, err := vlt.ExportData(*plain)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(*output, data, *force); err != nil {
+			return err
+		}
+		info("exported %d entries to %s", vlt.Len(), *output)
+		return nil
+	}
+}
+
+func cmdDiff(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 1 {
+			return errBadArgsDif
+		}
+		path := args[0]
+		entries, err := vault.ReadExport(path, func() (string, error) {
+			return readMasterPassword(fmt.Sprintf("password for %s: ", path))
+		})
+		if err != nil {
+			return err
+		}
+		added, removed, changed, err := vlt.Diff(entries)
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(struct {
+				Added   []string `json:"added"`
+				Removed []string `json:"removed"`
+				Changed []string `json:"changed"`
+			}{added, removed, changed})
+		}
+		for _, diff := range []struct {
+			mark  string
+			names []string
+		}{{"+", added}, {"-", removed}, {"~", changed}} {
+			for _, name := range diff.names {
+				fmt.Printf("%s %s\n", diff.mark, name)
+			}
+		}
+		return nil
+	}
+}
+
+func cmdImport(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	skip := fs.Bool("skip", false, "keep entries already in the vault")
+	overwrite := fs.Bool("overwrite", false, "replace entries already in the vault")
+	rename := fs.Bool("rename", false, "import clashing entries under a new name")
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 1 {
+			return errBadArgsImp
+		}
+		policy := vault.ImportFail
+		for _, p := range []struct {
+			set    bool
+			policy vault.ImportPolicy
+		}{{*skip, vault.ImportSkip}, {*overwrite, vault.ImportOverwrite}, {*rename, vault.ImportRename}} {
+			if p.set {
+				if policy != vault.ImportFail {
+					return errImportPolicy
+				}
+				policy = p.policy
+			}
+		}
+		path := args[0]
+		entries, err := vault.ReadExport(path, func() (string, error) {
+			return readMasterPassword(fmt.Sprintf("password for %s: ", path))
+		})
+		if err != nil {
+			return err
+		}
+		added, skipped, err := vlt.Merge(entries, policy)
+		if errors.Is(err, vault.ErrImportCollision) {
+			return fmt.Errorf("%w, choose -skip, -overwrite or -rename", err)
+		}
+		if err != nil {
+			return err
+		}
+		return saveVault(vlt, "imported %d, skipped %d", added, skipped)
+	}
+}
+
+func cmdImportCSV(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	var cols vault.CSVColumns
+	fs.StringVar(&cols.Name, "name-col", "name", "header of the entry name column")
+	fs.StringVar(&cols.Username, "username-col", "username", "header of the username column")
+	fs.StringVar(&cols.Password, "password-col", "password", "header of the password column")
+	fs.StringVar(&cols.URL, "url-col", "url", "header of the URL column")
+	fs.StringVar(&cols.Notes, "notes-col", "notes", "header of the notes column")
+	return func(vlt *vault.Vault, args []string) error {
+		if len(args) != 1 {
+			return errBadArgsCSV
+		}
+		fd, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		added, skipped, err := vlt.ImportCSV(fd, cols)
+		if err != nil {
+			return err
+		}
+		return saveVault(vlt, "imported %d, skipped %d", added, skipped)
+	}
+}
+
+func cmdUnlock(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	ttl := fs.Duration("ttl", 15*time.Minute, "forget the key after this long")
+	return func(vlt *vault.Vault, args []string) error {
+		if vaultFile == stdioVault {
+			return errStdioAgent
+		}
+		if *ttl <= 0 {
+			return errBadTTL
+		}
+		path, err := filepath.Abs(vaultFile)
+		if err != nil {
+			return err
+		}
+		if _, err := callAgent(agentRequest{Op: "ping"}); err != nil {
+			if err := startAgent(); err != nil {
+				return err
+			}
+		}
+		key, salt := vlt.Key()
+		defer zero(key)
+		if _, err := callAgent(agentRequest{Op: "add", Vault: path, Salt: salt, Key: key, TTL: *ttl}); err != nil {
+			return err
+		}
+		info("unlocked %s for %v", vaultFile, *ttl)
+		return nil
+	}
+}
+
+func cmdLock(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(_ *vault.Vault, args []string) error {
+		if _, err := callAgent(agentRequest{Op: "lock"}); err != nil {
+			info("no agent running")
+			return nil
+		}
+		info("locked")
+		return nil
+	}
+}
+
+func cmdAgent(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(_ *vault.Vault, args []string) error {
+		return runAgent()
+	}
+}
+
+func cmdRestore(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(_ *vault.Vault, args []string) error {
+		if vaultFile == stdioVault {
+			return errStdioRestore
+		}
+		if dryRun {
+			baks, err := vault.Backups(vaultFile)
+			if err != nil {
+				return err
+			}
+			if len(baks) == 0 {
+				return vault.ErrNoBackup
+			}
+			fmt.Fprintf(os.Stderr, "would restore vault from %s\n", baks[len(baks)-1])
+			fmt.Fprintln(os.Stderr, "dry run, vault not restored")
+			return nil
+		}
+		if err := vault.Restore(vaultFile); err != nil {
+			return err
+		}
+		info("restored vault from its latest backup")
+		return nil
+	}
+}
+
+func cmdShell(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(vlt *vault.Vault, args []string) error {
+		return shell(vlt)
+	}
+}
+
+func cmdCompletion(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(_ *vault.Vault, args []string) error {
+		if len(args) != 1 {
+			return errBadArgsCmp
+		}
+		return writeCompletion(os.Stdout, args[0])
+	}
+}
+
+func cmdVersion(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(_ *vault.Vault, args []string) error {
+		return printVersion()
+	}
+}
+
+func cmdHelp(fs *flag.FlagSet) func(*vault.Vault, []string) error {
+	return func(_ *vault.Vault, args []string) error {
+		switch len(args) {
+		case 0:
+			printUsage()
+			return nil
+		case 1:
+			return run(args[0], []string{"-h"})
+		}
+		return errBadArgsHlp
+	}
+}
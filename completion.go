@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"text/template"
+)
+
+var errBadShell = errors.New("unsupported shell, expected 'bash', 'zsh' or 'fish'")
+
+// Entry names are completed by running 'portunus lst' without stdin, so that
+// it fails rather than prompts unless PORTUNUS_PASSWORD is set.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for portunus
+_portunus() {
+	local cur="${COMP_WORDS[COMP_CWORD]}"
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "{{join .Commands " "}}" -- "$cur"))
+		return
+	fi
+	case "${COMP_WORDS[1]}" in
+	{{join .NameCommands "|"}})
+		local IFS=$'\n'
+		COMPREPLY=($(compgen -W "$(portunus lst 2>/dev/null </dev/null)" -- "$cur"))
+		;;
+	esac
+}
+complete -F _portunus portunus
+`,
+	"zsh": `#compdef portunus
+_portunus() {
+	if (( CURRENT == 2 )); then
+		compadd -- {{join .Commands " "}}
+		return
+	fi
+	case "$words[2]" in
+	({{join .NameCommands "|"}})
+		compadd -- ${(f)"$(portunus lst 2>/dev/null </dev/null)"}
+		;;
+	esac
+}
+compdef _portunus portunus
+`,
+	"fish": `# fish completion for portunus
+complete -c portunus -f
+{{range .Help}}complete -c portunus -n __fish_use_subcommand -a {{index . 0}} -d '{{quote (index . 1)}}'
+{{end}}complete -c portunus -n '__fish_seen_subcommand_from {{join .NameCommands " "}}' -a '(portunus lst 2>/dev/null </dev/null)'
+`,
+}
+
+// fishQuoter escapes text for a single-quoted fish string.
+var fishQuoter = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// writeCompletion writes the completion script for shell to w.
+func writeCompletion(w io.Writer, shell string) error {
+	src, ok := completionScripts[shell]
+	if !ok {
+		return errBadShell
+	}
+	funcs := template.FuncMap{"join": strings.Join, "quote": fishQuoter.Replace}
+	tmpl, err := template.New(shell).Funcs(funcs).Parse(src)
+	if err != nil {
+		return err
+	}
+	var data struct {
+		Commands     []string
+		NameCommands []string
+		Help         [][2]string
+	}
+	for _, cmd := range commands {
+		data.Commands = append(data.Commands, cmd.name)
+		data.Help = append(data.Help, [2]string{cmd.name, cmd.help})
+		if strings.HasPrefix(cmd.args, "NAME") || strings.HasPrefix(cmd.args, "OLD") {
+			data.NameCommands = append(data.NameCommands, cmd.name)
+		}
+	}
+	return tmpl.Execute(w, data)
+}
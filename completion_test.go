@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// unbalancedQuotes returns the first line of script that leaves a single or
+// double quote open, treating a backslash as escaping the next character.
+func unbalancedQuotes(script string) (string, bool) {
+	for _, line := range strings.Split(script, "\n") {
+		var quote rune
+		escaped := false
+		for _, r := range line {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case quote != 0:
+				if r == quote {
+					quote = 0
+				}
+			case r == '\'' || r == '"':
+				quote = r
+			}
+		}
+		if quote != 0 || escaped {
+			return line, true
+		}
+	}
+	return "", false
+}
+
+func TestCompletionQuotes(t *testing.T) {
+	for shell := range completionScripts {
+		var buf bytes.Buffer
+		if err := writeCompletion(&buf, shell); err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		if line, ok := unbalancedQuotes(buf.String()); ok {
+			t.Errorf("%s: unbalanced quotes in %q", shell, line)
+		}
+	}
+}
+
+func TestFishQuoter(t *testing.T) {
+	got := fishQuoter.Replace(`don't \ stop`)
+	if want := `don\'t \\ stop`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/patrickmcnamara/portunus/vault"
+)
+
+var (
+	errEditInvalid = errors.New("edited entry is not valid JSON")
+	errEditEmpty   = errors.New("edited entry has no password, notes or TOTP secret")
+)
+
+// editedEntry is the part of an entry that 'edit' lets the user change
+type editedEntry struct {
+	Password    string   `json:"password"`
+	Username    string   `json:"username"`
+	URL         string   `json:"url"`
+	Notes       string   `json:"notes"`
+	TOTP        string   `json:"totp"`
+	Tags        []string `json:"tags"`
+	RotateEvery string   `json:"rotate_every"`
+}
+
+// editor returns the command line of the user's editor, from $VISUAL or
+// $EDITOR, or else a default for the platform.
+func editor() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if args := strings.Fields(os.Getenv(env)); len(args) > 0 {
+			return args
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// editEntry writes e as JSON to a temporary file only its owner can access,
+// opens it in the user's editor and returns the edited entry, or false if the
+// file was left unchanged. The temporary file is overwritten and removed
+// before it returns.
+func editEntry(e vault.Entry) (vault.Entry, bool, error) {
+	ee := editedEntry{
+		Password: e.Password,
+		Username: e.Username,
+		URL:      e.URL,
+		Notes:    e.Notes,
+		TOTP:     e.TOTP,
+		Tags:     e.Tags,
+	}
+	if ee.Tags == nil {
+		ee.Tags = []string{}
+	}
+	if e.RotateEvery != 0 {
+		ee.RotateEvery = e.RotateEvery.String()
+	}
+	data, err := json.MarshalIndent(ee, "", "\t")
+	if err != nil {
+		return e, false, err
+	}
+	defer zero(data)
+	fd, err := ioutil.TempFile("", "portunus-*.json")
+	if err != nil {
+		return e, false, err
+	}
+	defer removeTemp(fd.Name())
+	_, err = fd.Write(append(data, '\n'))
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return e, false, err
+	}
+	args := editor()
+	cmd := exec.Command(args[0], append(args[1:], fd.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return e, false, fmt.Errorf("running editor: %w", err)
+	}
+	edited, err := ioutil.ReadFile(fd.Name())
+	if err != nil {
+		return e, false, err
+	}
+	defer zero(edited)
+	if bytes.Equal(bytes.TrimSpace(edited), data) {
+		return e, false, nil
+	}
+	ee = editedEntry{}
+	dec := json.NewDecoder(bytes.NewReader(edited))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&ee); err != nil {
+		return e, false, fmt.Errorf("%w: %v", errEditInvalid, err)
+	}
+	if ee.Password == "" && ee.Notes == "" && ee.TOTP == "" {
+		return e, false, errEditEmpty
+	}
+	e.Password, e.Username, e.URL, e.Notes, e.TOTP = ee.Password, ee.Username, ee.URL, ee.Notes, ee.TOTP
+	e.Tags, e.RotateEvery = ee.Tags, 0
+	if len(e.Tags) == 0 {
+		e.Tags = nil
+	}
+	if ee.RotateEvery != "" {
+		if e.RotateEvery, err = time.ParseDuration(ee.RotateEvery); err != nil {
+			return e, false, fmt.Errorf("%w: %v", errEditInvalid, err)
+		}
+	}
+	return e, true, nil
+}
+
+// removeTemp overwrites the temporary file at path with zeros, so that the
+// plaintext isn't left on disk, and removes it.
+func removeTemp(path string) {
+	if fi, err := os.Stat(path); err == nil {
+		ioutil.WriteFile(path, make([]byte, fi.Size()), 0600)
+	}
+	os.Remove(path)
+}
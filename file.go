@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"github.com/patrickmcnamara/portunus/vault"
+)
+
+// largest file stored by 'set -file' by default, as it is kept in the vault
+const defaultMaxFileSize = 64 * 1024
+
+var (
+	errFileTooLarge = errors.New("file is too large to store")
+	errNoFile       = errors.New("no file stored for entry")
+	errFileExists   = errors.New("file already exists, use -force to overwrite")
+	errBadMode      = errors.New("mode must be octal permissions that let the owner read and write, e.g. 600")
+	errStdioRestore = errors.New("a vault piped through stdin and stdout has no backups")
+)
+
+// stdioVault is the vault location meaning stdin and stdout, see stdioStore
+const stdioVault = "-"
+
+// readFileMax reads the file at path, failing if it is larger than max bytes.
+func readFileMax(path string, max int64) ([]byte, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(fd, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("%w, limit %d bytes, see -max-size", errFileTooLarge, max)
+	}
+	return data, nil
+}
+
+// linkFile is os.Link, which tests replace to act like a filesystem without
+// hard links
+var linkFile = os.Link
+
+// writeFile atomically writes data to a file at path that only its owner can
+// access. Unless force is set, it fails rather than overwrites an existing
+// file.
+func writeFile(path string, data []byte, force bool) error {
+	fd, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fd.Name())
+	_, err = fd.Write(data)
+	if err == nil {
+		err = fd.Sync()
+	}
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if force {
+		return os.Rename(fd.Name(), path)
+	}
+	// unlike renaming, linking fails if path exists
+	err = linkFile(fd.Name(), path)
+	if os.IsExist(err) {
+		return fmt.Errorf("%w: %s", errFileExists, path)
+	}
+	if err != nil {
+		// some filesystems, such as FAT on Windows, have no hard links
+		return writeNewFile(path, data)
+	}
+	return nil
+}
+
+// writeNewFile writes data to a new file at path that only its owner can
+// access, failing if the file exists. A failed write can leave the file
+// partly written.
+func writeNewFile(path string, data []byte) error {
+	fd, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return fmt.Errorf("%w: %s", errFileExists, path)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := fd.Write(data); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
+
+// modeFlag is the octal mode of the vault file, see -mode
+type modeFlag os.FileMode
+
+func (m *modeFlag) String() string {
+	return fmt.Sprintf("%04o", os.FileMode(*m))
+}
+
+func (m *modeFlag) Set(s string) error {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 || mode&0600 != 0600 {
+		return errBadMode
+	}
+	*m = modeFlag(mode)
+	return nil
+}
+
+// vaultStore returns the store of the vault file, saved with -mode, or of a
+// vault piped through stdin and stdout.
+func vaultStore() vault.Store {
+	if vaultFile == stdioVault {
+		return stdioStore{}
+	}
+	return &vault.FileStore{Path: vaultFile, Mode: os.FileMode(fileMode)}
+}
+
+// vaultOut is where stdioStore saves the vault, which stays stdout while a
+// command that changes the vault prints to stderr instead
+var vaultOut = os.Stdout
+
+// stdioStore loads a sealed vault from all of stdin, with empty input meaning
+// there is none yet, and saves it to stdout, so that vaults can be kept off
+// disk in pipelines.
+type stdioStore struct{}
+
+func (stdioStore) String() string {
+	return "stdin"
+}
+
+func (stdioStore) Load() ([]byte, error) {
+	data, err := ioutil.ReadAll(stdin)
+	if err == nil && len(data) == 0 {
+		err = os.ErrNotExist
+	}
+	return data, err
+}
+
+func (stdioStore) Save(data []byte) error {
+	_, err := vaultOut.Write(data)
+	return err
+}
+
+// checkPerms refuses the file at path like vault.CheckPerms unless -mode lets
+// other users access it, in which case it only refuses access beyond -mode.
+func checkPerms(path string) error {
+	if os.FileMode(fileMode)&0077 == 0 {
+		return vault.CheckPerms(path)
+	}
+	perm, err := filePerm(path)
+	if err != nil {
+		return err
+	}
+	if perm&^os.FileMode(fileMode) != 0 {
+		return fmt.Errorf("%w, mode %04o at %s", vault.ErrPerms, perm, path)
+	}
+	return nil
+}
+
+// warnPerms warns if the file at path, just written, allows more access than
+// -mode, as some filesystems ignore the mode files are given.
+func warnPerms(path string) {
+	perm, err := filePerm(path)
+	if err != nil || perm&^os.FileMode(fileMode) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: %s has mode %04o, allowing more access than %s\n", path, perm, &fileMode)
+}
+
+// filePerm returns the permissions of the file at path, or 0 on Windows, where
+// file modes don't reflect access permissions.
+func filePerm(path string) (os.FileMode, error) {
+	if runtime.GOOS == "windows" {
+		return 0, nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Mode().Perm(), nil
+}
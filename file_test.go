@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	for _, links := range []bool{true, false} {
+		dir, err := ioutil.TempDir("", "portunus")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		if !links {
+			defer func() { linkFile = os.Link }()
+			linkFile = func(_, _ string) error { return errors.New("hard links not supported") }
+		}
+		path := filepath.Join(dir, "out")
+		if err := writeFile(path, []byte("one"), false); err != nil {
+			t.Fatalf("links %v: %v", links, err)
+		}
+		if err := writeFile(path, []byte("two"), false); !errors.Is(err, errFileExists) {
+			t.Errorf("links %v: writing over a file without force: got %v, want %v", links, err, errFileExists)
+		}
+		if data, _ := ioutil.ReadFile(path); string(data) != "one" {
+			t.Errorf("links %v: file holds %q after refusing to overwrite it, want one", links, data)
+		}
+		if err := writeFile(path, []byte("three"), true); err != nil {
+			t.Errorf("links %v: writing over a file with force: %v", links, err)
+		}
+		if data, _ := ioutil.ReadFile(path); string(data) != "three" {
+			t.Errorf("links %v: file holds %q, want three", links, data)
+		}
+		if fis, _ := ioutil.ReadDir(dir); len(fis) != 1 {
+			t.Errorf("links %v: %d files left, want 1", links, len(fis))
+		}
+	}
+}
+
+func TestFilePerm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "portunus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "portunus.json")
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	perm, err := filePerm(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Windows file modes don't reflect access permissions
+	want := os.FileMode(0644)
+	if runtime.GOOS == "windows" {
+		want = 0
+	}
+	if perm != want {
+		t.Errorf("filePerm = %04o, want %04o", perm, want)
+	}
+}
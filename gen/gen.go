@@ -0,0 +1,305 @@
+// Package gen generates random passwords and passphrases.
+package gen
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	// generated password length, in random bytes or characters
+	DefaultLength = 12
+	MinLength     = 4
+	MaxLength     = 256
+
+	// character classes
+	lowers    = "abcdefghijklmnopqrstuvwxyz"
+	uppers    = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	letters   = lowers + uppers
+	digits    = "0123456789"
+	symbols   = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+	ambiguous = "0OoIl1|`'\""
+
+	// passphrase length in words
+	MaxWords = 64
+)
+
+var (
+	ErrBadLength   = fmt.Errorf("length must be between %d and %d", MinLength, MaxLength)
+	ErrBadMinimum  = errors.New("minimum character counts must not be negative")
+	ErrMinTooLarge = errors.New("minimum character counts add up to more than the length")
+	ErrBadWords    = fmt.Errorf("number of words must be between 1 and %d", MaxWords)
+	ErrBadFormat   = errors.New("unknown format, choose base64url, hex or base32")
+	ErrFormatClass = errors.New("a format can't be combined with character classes or minimum counts")
+	ErrBadPattern  = fmt.Errorf("patterns must be 1 to %d of L (letter), l (lower case), u (upper case), d (digit), s (symbol) and a (any)", MaxLength)
+)
+
+// patternClasses are the characters each pattern token stands for
+var patternClasses = map[rune]string{
+	'L': letters,
+	'l': lowers,
+	'u': uppers,
+	'd': digits,
+	's': symbols,
+	'a': letters + digits + symbols,
+}
+
+// Reader is the source of the randomness in generated passwords. It can be
+// replaced with a fixed stream to test code using this package, but must
+// otherwise be left as a cryptographically secure generator.
+var Reader io.Reader = rand.Reader
+
+// readRandom fills b from Reader. Without randomness no password is safe, so
+// it panics if reading fails.
+func readRandom(b []byte) {
+	if _, err := io.ReadFull(Reader, b); err != nil {
+		panic("gen: reading random bytes: " + err.Error())
+	}
+}
+
+// formats encode random bytes as a password
+var formats = map[string]func([]byte) string{
+	"base64url": base64.RawURLEncoding.EncodeToString,
+	"hex":       hex.EncodeToString,
+	"base32":    base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString,
+}
+
+// Charset selects the character classes a generated password is drawn from,
+// and how many characters it must have from each. Without any classes it
+// means random bytes encoded as Format, base64url by default.
+type Charset struct {
+	Format string
+
+	Letters     bool
+	Digits      bool
+	Symbols     bool
+	NoAmbiguous bool
+
+	MinLower   int
+	MinUpper   int
+	MinDigits  int
+	MinSymbols int
+}
+
+// alphabet returns the characters selected by cs, or "" if cs selects
+// encoded random bytes. Asking only for no ambiguous characters or minimum
+// counts selects every class, and a minimum count for a class selects that
+// class.
+func (cs Charset) alphabet() string {
+	if cs.random() {
+		return ""
+	}
+	if !cs.Letters && !cs.Digits && !cs.Symbols {
+		cs.Letters, cs.Digits, cs.Symbols = true, true, true
+	}
+	var b strings.Builder
+	if cs.Letters || cs.MinLower > 0 || cs.MinUpper > 0 {
+		b.WriteString(letters)
+	}
+	if cs.Digits || cs.MinDigits > 0 {
+		b.WriteString(digits)
+	}
+	if cs.Symbols || cs.MinSymbols > 0 {
+		b.WriteString(symbols)
+	}
+	return cs.filter(b.String())
+}
+
+// random reports whether cs selects encoded random bytes rather than
+// characters from classes.
+func (cs Charset) random() bool {
+	cs.Format = ""
+	return cs == Charset{}
+}
+
+// filter removes ambiguous characters from alpha if cs asks for it.
+func (cs Charset) filter(alpha string) string {
+	if !cs.NoAmbiguous {
+		return alpha
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(ambiguous, r) {
+			return -1
+		}
+		return r
+	}, alpha)
+}
+
+// Check reports whether a password of n characters can satisfy the minimum
+// counts of cs.
+func (cs Charset) Check(n int) error {
+	if cs.Format != "" {
+		if _, ok := formats[cs.Format]; !ok {
+			return ErrBadFormat
+		}
+		if !cs.random() {
+			return ErrFormatClass
+		}
+	}
+	if cs.MinLower < 0 || cs.MinUpper < 0 || cs.MinDigits < 0 || cs.MinSymbols < 0 {
+		return ErrBadMinimum
+	}
+	if cs.MinLower+cs.MinUpper+cs.MinDigits+cs.MinSymbols > n {
+		return ErrMinTooLarge
+	}
+	return nil
+}
+
+// Password returns a password of n random bytes encoded as cs.Format if cs
+// selects no character classes, or else of n characters drawn uniformly from
+// the alphabet of cs. The characters required by the minimum counts of cs are
+// drawn from their classes first and then shuffled in with the rest.
+func Password(n int, cs Charset) string {
+	alpha := cs.alphabet()
+	if alpha == "" {
+		pswd := make([]byte, n)
+		readRandom(pswd)
+		encode, ok := formats[cs.Format]
+		if !ok {
+			encode = formats["base64url"]
+		}
+		return encode(pswd)
+	}
+	pswd := make([]byte, 0, n)
+	for _, req := range []struct {
+		class string
+		min   int
+	}{{lowers, cs.MinLower}, {uppers, cs.MinUpper}, {digits, cs.MinDigits}, {symbols, cs.MinSymbols}} {
+		class := cs.filter(req.class)
+		for i := 0; i < req.min; i++ {
+			pswd = append(pswd, class[randIndex(len(class))])
+		}
+	}
+	for len(pswd) < n {
+		pswd = append(pswd, alpha[randIndex(len(alpha))])
+	}
+	for i := len(pswd) - 1; i > 0; i-- {
+		j := randIndex(i + 1)
+		pswd[i], pswd[j] = pswd[j], pswd[i]
+	}
+	return string(pswd)
+}
+
+// CheckPattern reports whether p is a valid pattern, see Charset.Pattern.
+func CheckPattern(p string) error {
+	if p == "" || len(p) > MaxLength {
+		return ErrBadPattern
+	}
+	for _, r := range p {
+		if _, ok := patternClasses[r]; !ok {
+			return ErrBadPattern
+		}
+	}
+	return nil
+}
+
+// Pattern returns a password with a character for each token of the pattern
+// p, drawn uniformly from the class the token stands for: L for letters, l
+// for lower case letters, u for upper case letters, d for digits, s for
+// symbols and a for any of them. Only the NoAmbiguous setting of cs is used.
+func (cs Charset) Pattern(p string) string {
+	pswd := make([]byte, 0, len(p))
+	for _, r := range p {
+		class := cs.filter(patternClasses[r])
+		pswd = append(pswd, class[randIndex(len(class))])
+	}
+	return string(pswd)
+}
+
+// PatternEntropy returns the entropy in bits of a password generated from
+// the pattern p with cs.
+func (cs Charset) PatternEntropy(p string) float64 {
+	var bits float64
+	for _, r := range p {
+		bits += Entropy(len(cs.filter(patternClasses[r])), 1)
+	}
+	return bits
+}
+
+// Phrase configures generated passphrases
+type Phrase struct {
+	Words      int
+	Separator  string
+	Capitalise bool
+	Digit      bool
+}
+
+// Passphrase returns pp.Words words drawn uniformly from the EFF long
+// wordlist and joined by pp.Separator, optionally capitalised and with a
+// random digit added to the end of a random word.
+func Passphrase(pp Phrase) string {
+	words := make([]string, pp.Words)
+	for i := range words {
+		words[i] = wordlist[randIndex(len(wordlist))]
+		if pp.Capitalise {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	if pp.Digit {
+		words[randIndex(len(words))] += string(digits[randIndex(len(digits))])
+	}
+	return strings.Join(words, pp.Separator)
+}
+
+// Entropy returns the entropy in bits of n symbols each drawn uniformly from
+// an alphabet of size symbols.
+func Entropy(size, n int) float64 {
+	return float64(n) * math.Log2(float64(size))
+}
+
+// Entropy returns the entropy in bits of a password of n characters generated
+// with cs. Minimum counts slightly reduce the real figure and are ignored.
+func (cs Charset) Entropy(n int) float64 {
+	alpha := cs.alphabet()
+	if alpha == "" {
+		return Entropy(256, n)
+	}
+	return Entropy(len(alpha), n)
+}
+
+// Entropy returns the entropy in bits of a passphrase generated with pp.
+func (pp Phrase) Entropy() float64 {
+	bits := Entropy(len(wordlist), pp.Words)
+	if pp.Digit {
+		bits += Entropy(len(digits), 1) + Entropy(pp.Words, 1)
+	}
+	return bits
+}
+
+// CheckWords reports whether n is an allowed number of passphrase words.
+func CheckWords(n int) error {
+	if n < 1 || n > MaxWords {
+		return ErrBadWords
+	}
+	return nil
+}
+
+// randIndex returns a uniformly random integer in [0, n). Random values that
+// would bias the result towards lower indices are rejected and redrawn.
+func randIndex(n int) int {
+	max := 1<<32 - 1<<32%uint64(n)
+	var buf [4]byte
+	for {
+		readRandom(buf[:])
+		v := uint64(binary.BigEndian.Uint32(buf[:]))
+		if v < max {
+			return int(v % uint64(n))
+		}
+	}
+}
+
+// CheckLength reports whether n is an allowed password length.
+func CheckLength(n int) error {
+	if n < MinLength || n > MaxLength {
+		return ErrBadLength
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package gen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+// stream returns a reader of vals as the big endian 32-bit values randIndex
+// draws.
+func stream(vals ...uint32) io.Reader {
+	var buf bytes.Buffer
+	for _, v := range vals {
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	return &buf
+}
+
+// withReader runs f with Reader replaced by r.
+func withReader(r io.Reader, f func()) {
+	defer func(old io.Reader) { Reader = old }(Reader)
+	Reader = r
+	f()
+}
+
+func TestRandIndex(t *testing.T) {
+	tests := []struct {
+		n    int
+		vals []uint32
+		want int
+	}{
+		{10, []uint32{0}, 0},
+		{10, []uint32{13}, 3},
+		{1, []uint32{1<<32 - 1}, 0},
+		// 2^32 % 3 == 1, so the largest value would favour 0 and is redrawn
+		{3, []uint32{1<<32 - 1, 5}, 2},
+		// 2^32 % 10 == 6, so the 6 largest values are redrawn
+		{10, []uint32{1<<32 - 6, 1<<32 - 1, 1<<32 - 7}, 9},
+	}
+	for _, test := range tests {
+		var got int
+		withReader(stream(test.vals...), func() { got = randIndex(test.n) })
+		if got != test.want {
+			t.Errorf("randIndex(%d) with %v = %d, want %d", test.n, test.vals, got, test.want)
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	tests := []struct {
+		n    int
+		cs   Charset
+		in   io.Reader
+		want string
+	}{
+		{4, Charset{Format: "hex"}, bytes.NewReader([]byte{0, 1, 0xab, 0xff}), "0001abff"},
+		{5, Charset{Format: "base32"}, bytes.NewReader([]byte{0, 0, 0, 0, 0}), "AAAAAAAA"},
+		{3, Charset{}, bytes.NewReader([]byte{0xfb, 0xff, 0xbf}), "-_-_"},
+		// four digits, then a shuffle that swaps nothing
+		{4, Charset{Digits: true}, stream(1, 2, 3, 4, 3, 2, 1), "1234"},
+		// a symbol for the minimum count, a digit or symbol, then a swap
+		{2, Charset{Digits: true, MinSymbols: 1}, stream(0, 5, 0), "5!"},
+		// without 0 and 1, index 0 is 2
+		{1, Charset{Digits: true, NoAmbiguous: true}, stream(0), "2"},
+	}
+	for _, test := range tests {
+		var got string
+		withReader(test.in, func() { got = Password(test.n, test.cs) })
+		if got != test.want {
+			t.Errorf("Password(%d, %+v) = %q, want %q", test.n, test.cs, got, test.want)
+		}
+	}
+}
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		cs      Charset
+		vals    []uint32
+		want    string
+	}{
+		{"dLu", Charset{}, []uint32{7, 27, 25}, "7BZ"},
+		{"ls", Charset{}, []uint32{0, 1}, `a"`},
+		{"da", Charset{NoAmbiguous: true}, []uint32{0, 0}, "2a"},
+	}
+	for _, test := range tests {
+		var got string
+		withReader(stream(test.vals...), func() { got = test.cs.Pattern(test.pattern) })
+		if got != test.want {
+			t.Errorf("Pattern(%q) with %+v = %q, want %q", test.pattern, test.cs, got, test.want)
+		}
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	title := func(w string) string { return strings.ToUpper(w[:1]) + w[1:] }
+	tests := []struct {
+		pp   Phrase
+		vals []uint32
+		want string
+	}{
+		{Phrase{Words: 2, Separator: " "}, []uint32{0, 1}, wordlist[0] + " " + wordlist[1]},
+		{Phrase{Words: 1, Separator: "-"}, []uint32{uint32(len(wordlist)) + 2}, wordlist[2]},
+		// the digit 7 is added to the second word
+		{Phrase{Words: 2, Separator: "-", Capitalise: true, Digit: true}, []uint32{3, 4, 1, 7},
+			title(wordlist[3]) + "-" + title(wordlist[4]) + "7"},
+	}
+	for _, test := range tests {
+		var got string
+		withReader(stream(test.vals...), func() { got = Passphrase(test.pp) })
+		if got != test.want {
+			t.Errorf("Passphrase(%+v) = %q, want %q", test.pp, got, test.want)
+		}
+	}
+}
+
+func TestReadRandomFails(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Password with an exhausted Reader didn't panic")
+		}
+	}()
+	withReader(stream(), func() { Password(4, Charset{Digits: true}) })
+}
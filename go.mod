@@ -2,4 +2,7 @@ module github.com/patrickmcnamara/portunus
 
 go 1.13
 
-require golang.org/x/crypto v0.0.0-20191108234033-bd318be0434a
+require (
+	golang.org/x/crypto v0.0.0-20191108234033-bd318be0434a
+	golang.org/x/text v0.3.7
+)
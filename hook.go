@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// onChangeCmd returns the hook command from -on-change or else the
+// PORTUNUS_ON_CHANGE environment variable, or "" for no hook.
+func onChangeCmd() string {
+	if onChange != "" {
+		return onChange
+	}
+	return os.Getenv("PORTUNUS_ON_CHANGE")
+}
+
+// runHook runs the hook command, if there is one, through the shell after the
+// vault has been saved. The names of the entries added, changed and removed
+// are given one per line in PORTUNUS_ADDED, PORTUNUS_CHANGED and
+// PORTUNUS_REMOVED, and the vault file in PORTUNUS_VAULT, but it isn't given
+// PORTUNUS_PASSWORD. The hook's output goes to stderr, and its failure is
+// only warned about, as the vault has already been saved.
+func runHook(added, changed, removed []string) {
+	hook := onChangeCmd()
+	if hook == "" {
+		return
+	}
+	cmd := exec.Command("sh", "-c", hook)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", hook)
+	}
+	cmd.Env = append(childEnv(),
+		"PORTUNUS_VAULT="+vaultFile,
+		"PORTUNUS_ADDED="+strings.Join(added, "\n"),
+		"PORTUNUS_CHANGED="+strings.Join(changed, "\n"),
+		"PORTUNUS_REMOVED="+strings.Join(removed, "\n"),
+	)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: running -on-change hook: %v\n", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// logEntry is a line of the audit log, never holding secrets
+type logEntry struct {
+	Time   time.Time `json:"time"`
+	Vault  string    `json:"vault"`
+	Action string    `json:"action"`
+	Name   string    `json:"name"`
+}
+
+// auditLogPath returns the audit log location from -audit-log or else the
+// PORTUNUS_AUDIT_LOG environment variable, or "" for no audit log.
+func auditLogPath() string {
+	if auditLog != "" {
+		return auditLog
+	}
+	return os.Getenv("PORTUNUS_AUDIT_LOG")
+}
+
+// logChanges appends a JSON line for every entry added, changed or removed
+// to the audit log, if there is one. Logging is best effort: failures are
+// only warned about, as the vault has already been saved.
+func logChanges(added, changed, removed []string) {
+	path := auditLogPath()
+	if path == "" {
+		return
+	}
+	if err := appendLog(path, added, changed, removed); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: writing audit log: %v\n", err)
+	}
+}
+
+func appendLog(path string, added, changed, removed []string) error {
+	fd, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(fd)
+	now := time.Now().UTC()
+	for _, change := range []struct {
+		action string
+		names  []string
+	}{{"add", added}, {"change", changed}, {"remove", removed}} {
+		for _, name := range change.names {
+			if err := enc.Encode(logEntry{now, vaultFile, change.action, name}); err != nil {
+				fd.Close()
+				return err
+			}
+		}
+	}
+	return fd.Close()
+}
@@ -1,184 +1,675 @@
 package main
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"bufio"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
-	"path/filepath"
-	"sort"
-	"sync"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/patrickmcnamara/portunus/gen"
+	"github.com/patrickmcnamara/portunus/vault"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
 var (
-	// vaultFile is the vault file location
+	// vaultFile is the vault file location, see vaultLocation
 	configDir, _ = os.UserConfigDir()
-	vaultFile    = filepath.Join(configDir, "portunus.json")
+	vaultFile    string
 
-	// vault errors
-	errVaultExists      = errors.New("vault file already exists at " + vaultFile)
-	errVaultNotExists   = errors.New("no vault file found at " + vaultFile)
-	errVaultInvalid     = errors.New("invalid vault file at " + vaultFile)
-	errVaultNoSuchValue = errors.New("no such value in vault")
+	// stdin is shared by everything reading lines of piped input
+	stdin = bufio.NewReader(os.Stdin)
+
+	// passwordInput reads master passwords from -password-fd, once opened
+	passwordInput *bufio.Reader
+
+	// global flags
+	jsonOutput   bool
+	vaultPath    string
+	vaultName    string
+	insecure     bool
+	dryRun       bool
+	quiet        bool
+	fileMode     = modeFlag(0600)
+	passwordFD   int
+	auditLog     string
+	onChange     string
+	readOnlyFlag bool
+	timeout      time.Duration
+	showVersion  bool
+
+	// confirmation errors
+	errNotOverwritten = errors.New("not overwriting existing value, use -force to overwrite")
+	errNotPurged      = errors.New("not purging vault, use -yes to confirm")
+	errNotRemoved     = errors.New("not removing entries, use -yes to confirm")
+
+	// password input errors
+	errNoPassword     = errors.New("no password given")
+	errNoNotes        = errors.New("no notes given")
+	errMasterMismatch = errors.New("master passwords do not match")
+	errWrongMaster    = errors.New("wrong master password")
+	errPswdMismatch   = errors.New("passwords do not match")
+	errPromptTimeout  = errors.New("no input given in time, see -prompt-timeout")
 
 	// argument parsing errors
-	errBadArgs    = errors.New("possible subcommands 'vlt', 'get', 'set', 'new', 'lst', 'gen'")
-	errBadArgsSet = errors.New("'set' takes one argument, 'name'")
-	errBadArgsNew = errors.New("'new' takes one argument, 'name'")
-	errBadArgsGet = errors.New("'get' takes one argument, 'name'")
-	errBadArgsGen = errors.New("'gen' takes one argument, 'name'")
+	errBadArgs     = errors.New("missing or unknown subcommand, run 'portunus help' for usage")
+	errNoShell     = errors.New("command can't be run in the shell")
+	errReadOnly    = errors.New("command changes the vault, which -read-only forbids")
+	errBadArgsSet  = errors.New("'set' takes one argument, 'name'")
+	errBadArgsNew  = errors.New("'new' takes one argument, 'name'")
+	errBadArgsGet  = errors.New("'get' takes one argument, 'name'")
+	errBadArgsRem  = errors.New("'rem' takes at least one argument, 'name', or none with -tag")
+	errBadArgsRen  = errors.New("'rename' takes two arguments, 'old' and 'new'")
+	errBadArgsGen  = errors.New("'gen' takes one argument, 'name'")
+	errBadArgsOTP  = errors.New("'otp' takes one argument, 'name'")
+	errBadArgsFnd  = errors.New("'find' takes one argument, 'query'")
+	errBadArgsExp  = errors.New("'export' takes one argument, 'file', or -output")
+	errBadArgsImp  = errors.New("'import' takes one argument, 'file'")
+	errBadArgsDif  = errors.New("'diff' takes one argument, 'file'")
+	errBadArgsCSV  = errors.New("'import-csv' takes one argument, 'file'")
+	errBadArgsHlp  = errors.New("'help' takes at most one argument, 'command'")
+	errBadArgsCmp  = errors.New("'completion' takes one argument, 'shell'")
+	errBadArgsHst  = errors.New("'history' takes one argument, 'name'")
+	errBadArgsNte  = errors.New("'note' takes one argument, 'name'")
+	errBadArgsBat  = errors.New("'batch' takes no arguments")
+	errBadArgsLst  = errors.New("'lst' takes at most one argument, 'prefix'")
+	errBadArgsEdt  = errors.New("'edit' takes one argument, 'name'")
+	errBadArgsAls  = errors.New("'alias' takes no arguments, or two, 'alias' and 'target'")
+	errTreeFlags   = errors.New("-tree can't be combined with -show, -long or -json")
+	errBadCount    = errors.New("count must be at least 1")
+	errBadPage     = errors.New("-limit and -offset must not be negative")
+	errGenMode     = errors.New("choose only one of -words and -pattern")
+	errSetGenerate = errors.New("-generate can't be combined with -totp or -file")
+	errTypeFlags   = errors.New("-type can't be combined with -clip, -output or -file")
+	errAllFlags    = errors.New("-all can't be combined with -field, -clip, -type, -output or -file")
+	errBadSince    = errors.New("expected a duration such as 36h, 7d or 2w, or a time such as 2006-01-02 or RFC 3339")
+	errBatchLine   = errors.New("expected NAME<TAB>PASSWORD")
+
+	// field errors
+	errBadField = errors.New("unknown field, choose password, username, url or notes")
+	errNoField  = errors.New("entry has no value for field")
+
+	// verification errors
+	errVerify = errors.New("problems with vault entries")
+
+	// import errors
+	errImportPolicy = errors.New("choose only one of -skip, -overwrite and -rename")
 )
 
-type vault struct {
-	vlt  map[string]string
-	lock sync.Mutex
+// openVault opens the vault file with the master password, first checking
+// its permissions unless -insecure-perms is set. Only 'vlt' creates the vault
+// file, so every other command that needs it points there if it is missing.
+func openVault() (*vault.Vault, error) {
+	if !insecure && vaultFile != stdioVault {
+		err := checkPerms(vaultFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w, run 'chmod %o' on it or pass -insecure-perms", err, fileMode)
+		}
+	}
+	if vlt, err := openWithAgent(); err == nil {
+		return vlt, nil
+	}
+	vlt, err := vault.OpenStore(vaultStore(), func() (string, error) {
+		return readMasterPassword("master password: ")
+	})
+	if errors.Is(err, vault.ErrNotExists) {
+		return nil, fmt.Errorf("%w, run 'portunus vlt' to create one", err)
+	}
+	if errors.Is(err, vault.ErrEmpty) {
+		return nil, fmt.Errorf("%w, run 'portunus restore' to restore its latest backup", err)
+	}
+	return vlt, err
 }
 
-func newVault() (*vault, error) {
-	vlt := &vault{vlt: make(map[string]string)}
-	fd, err := os.OpenFile(vaultFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
-	if err != nil {
-		if errors.Is(err, os.ErrExist) {
-			return nil, errVaultExists
+// saveVault saves vlt, logs the entries it changed to the audit log, runs the
+// -on-change hook and confirms it with a message formatted from format and
+// args or, with -dry-run, prints what saving it would change.
+func saveVault(vlt *vault.Vault, format string, args ...interface{}) error {
+	added, changed, removed := vlt.Changes()
+	if !dryRun {
+		if err := vlt.Save(); err != nil {
+			return err
 		}
-		return nil, err
+		warnPerms(vaultFile)
+		logChanges(added, changed, removed)
+		runHook(added, changed, removed)
+		info(format, args...)
+		return nil
 	}
-	defer fd.Close()
-	_, err = fd.WriteString("{}")
-	return vlt, err
+	for _, change := range []struct {
+		verb  string
+		names []string
+	}{{"add", added}, {"change", changed}, {"remove", removed}} {
+		for _, name := range change.names {
+			fmt.Fprintf(os.Stderr, "would %s %s\n", change.verb, name)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "dry run, vault not saved")
+	return nil
 }
 
-func openVault() (*vault, error) {
-	vlt := &vault{vlt: make(map[string]string)}
-	data, err := ioutil.ReadFile(vaultFile)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil, errVaultNotExists
+// readPassword reads a password from the terminal without echoing it or, if
+// stdin is not a terminal, the next line of stdin. Empty input is an error.
+func readPassword() (string, error) {
+	var pswd string
+	if fd := int(os.Stdin.Fd()); terminal.IsTerminal(fd) {
+		state, err := terminal.GetState(fd)
+		if err != nil {
+			return "", err
+		}
+		pswd, err = withTimeout(func() (string, error) {
+			buf, err := terminal.ReadPassword(fd)
+			defer zero(buf)
+			return string(buf), err
+		})
+		if err == errPromptTimeout {
+			terminal.Restore(fd, state)
+		}
+		if err != nil {
+			return "", err
+		}
+	} else {
+		var err error
+		if pswd, err = readLine(stdin); err != nil {
+			return "", err
 		}
-		return nil, err
 	}
-	err = json.Unmarshal(data, &vlt.vlt)
-	if err != nil {
-		return nil, errVaultInvalid
+	if pswd == "" {
+		return "", errNoPassword
 	}
-	return vlt, nil
+	return pswd, nil
 }
 
-func (vlt *vault) saveVault() error {
-	data, _ := json.Marshal(vlt.vlt)
-	err := ioutil.WriteFile(vaultFile, data, 0600)
-	return err
+// readMasterPassword returns the next line of the file descriptor given with
+// -password-fd, or else the master password from the PORTUNUS_PASSWORD
+// environment variable if it is set, otherwise it prompts for it on the
+// terminal.
+func readMasterPassword(prompt string) (string, error) {
+	if passwordFD >= 0 {
+		return readPasswordFD()
+	}
+	if pswd, ok := os.LookupEnv("PORTUNUS_PASSWORD"); ok {
+		return pswd, nil
+	}
+	return promptPassword(prompt)
 }
 
-func (vlt *vault) set(name string) {
-	vlt.lock.Lock()
-	defer vlt.lock.Unlock()
-	vlt.vlt[name] = readPassword()
+// childEnv returns the environment for commands portunus runs, without
+// PORTUNUS_PASSWORD, so that the master password isn't passed on to them.
+func childEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "PORTUNUS_PASSWORD=") {
+			env = append(env, kv)
+		}
+	}
+	return env
 }
 
-func (vlt *vault) new(name string) {
-	vlt.lock.Lock()
-	defer vlt.lock.Unlock()
-	vlt.vlt[name] = generatePassword()
+// readPasswordFD reads the next line of the file descriptor given with
+// -password-fd, so that master passwords and piped input to the command don't
+// share stdin.
+func readPasswordFD() (string, error) {
+	if passwordInput == nil {
+		passwordInput = stdin
+		if passwordFD != 0 {
+			passwordInput = bufio.NewReader(os.NewFile(uintptr(passwordFD), "password-fd"))
+		}
+	}
+	pswd, err := readLine(passwordInput)
+	if err != nil {
+		return "", err
+	}
+	if pswd == "" {
+		return "", errNoPassword
+	}
+	return pswd, nil
 }
 
-func (vlt *vault) get(name string) (string, error) {
-	vlt.lock.Lock()
-	defer vlt.lock.Unlock()
-	pswd, ok := vlt.vlt[name]
-	if !ok {
-		return "", errVaultNoSuchValue
+// newMasterPassword reads a new master password and its confirmation with
+// read.
+func newMasterPassword(read func(prompt string) (string, error)) (string, error) {
+	pswd, err := read("new master password: ")
+	if err != nil {
+		return "", err
+	}
+	confirmed, err := read("confirm master password: ")
+	if err != nil {
+		return "", err
+	}
+	if !equalSecrets(confirmed, pswd) {
+		return "", errMasterMismatch
 	}
 	return pswd, nil
 }
 
-func (vlt *vault) rem(name string) error {
-	vlt.lock.Lock()
-	defer vlt.lock.Unlock()
-	if _, ok := vlt.vlt[name]; !ok {
-		return errVaultNoSuchValue
+// readNewPassword reads a password to be stored. On a terminal it is asked for
+// twice, to catch typos that can't be seen.
+func readNewPassword() (string, error) {
+	pswd, err := promptPassword("password: ")
+	if err != nil || !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return pswd, err
 	}
-	delete(vlt.vlt, name)
-	return nil
+	confirmed, err := promptPassword("confirm password: ")
+	if err != nil {
+		return "", err
+	}
+	if !equalSecrets(confirmed, pswd) {
+		return "", errPswdMismatch
+	}
+	return pswd, nil
 }
 
-func (vlt *vault) lst() []string {
-	names := make([]string, len(vlt.vlt))
-	var i int
-	for name := range vlt.vlt {
-		names[i] = name
-		i++
+// readNotes reads free-form, possibly multi-line, text up to the end of stdin,
+// without its final newline. Empty input is an error.
+func readNotes() (string, error) {
+	if terminal.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, "notes, end with Ctrl-D:")
+	}
+	notes, err := withTimeout(func() (string, error) {
+		data, err := ioutil.ReadAll(stdin)
+		return string(data), err
+	})
+	if err != nil {
+		return "", err
 	}
-	sort.Strings(names)
-	return names
+	notes = strings.TrimRight(notes, "\r\n")
+	if notes == "" {
+		return "", errNoNotes
+	}
+	return notes, nil
 }
 
-func generatePassword() string {
-	pswd := make([]byte, 12)
-	rand.Read(pswd)
-	return base64.RawURLEncoding.EncodeToString(pswd)
+// readLine reads the next line of r, without its line ending.
+func readLine(r *bufio.Reader) (string, error) {
+	return withTimeout(func() (string, error) {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+	})
 }
 
-func readPassword() string {
-	buf, _ := terminal.ReadPassword(int(os.Stdin.Fd()))
-	return string(buf)
+// withTimeout returns the result of read, or errPromptTimeout if it takes
+// longer than -prompt-timeout, so that commands waiting for input that never
+// comes fail rather than hang. read is left running, so the caller should
+// give up on its input.
+func withTimeout(read func() (string, error)) (string, error) {
+	if timeout <= 0 {
+		return read()
+	}
+	type result struct {
+		s   string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		s, err := read()
+		done <- result{s, err}
+	}()
+	select {
+	case r := <-done:
+		return r.s, r.err
+	case <-time.After(timeout):
+		return "", errPromptTimeout
+	}
+}
+
+// promptPassword reads a password, first printing prompt if stdin is a
+// terminal.
+func promptPassword(prompt string) (string, error) {
+	if terminal.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprint(os.Stderr, prompt)
+		defer fmt.Fprintln(os.Stderr)
+	}
+	return readPassword()
 }
 
 func main() {
-	if len(os.Args) < 2 {
+	global := newFlagSet("portunus")
+	globalFlags(global)
+	err := global.Parse(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		printUsage()
+		return
+	}
+	chk(err)
+	if showVersion {
+		chk(printVersion())
+		return
+	}
+	vaultFile, err = vaultLocation(vaultPath, vaultName, os.Getenv)
+	chk(err)
+	if global.NArg() < 1 {
 		chk(errBadArgs)
 	}
+	chk(run(global.Arg(0), global.Args()[1:]))
+}
 
-	vlt, err := openVault()
-	if err != nil && os.Args[1] != "new" {
-		chk(err)
-	}
-
-	switch os.Args[1] {
-	case "vlt":
-		_, err := newVault()
-		chk(err)
-	case "set":
-		if len(os.Args) != 3 {
-			chk(errBadArgsSet)
-		}
-		name := os.Args[2]
-		vlt.set(name)
-		chk(vlt.saveVault())
-	case "new":
-		if len(os.Args) != 3 {
-			chk(errBadArgsNew)
-		}
-		name := os.Args[2]
-		vlt.new(name)
-		chk(vlt.saveVault())
-	case "get":
-		if len(os.Args) != 3 {
-			chk(errBadArgsGet)
-		}
-		name := os.Args[2]
-		pswd, err := vlt.get(name)
-		chk(err)
-		fmt.Println(pswd)
-	case "lst":
-		for _, name := range vlt.lst() {
-			fmt.Println(name)
-		}
-	case "gen":
-		fmt.Println(generatePassword())
-	default:
-		chk(errBadArgs)
+// globalFlags defines the flags given before the subcommand on fs.
+func globalFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&jsonOutput, "json", false, "print output as JSON")
+	fs.StringVar(&vaultPath, "vault", "", "use the vault file at this path")
+	fs.StringVar(&vaultPath, "f", "", "shorthand for -vault")
+	fs.StringVar(&vaultName, "name", "", "use the named vault, see 'portunus vaults'")
+	fs.BoolVar(&insecure, "insecure-perms", false, "open the vault even if other users can access the file")
+	fs.BoolVar(&dryRun, "dry-run", false, "show what a command would change without saving the vault")
+	fs.BoolVar(&quiet, "quiet", false, "only print requested output, prompts and errors")
+	fs.BoolVar(&quiet, "q", false, "shorthand for -quiet")
+	fs.Var(&fileMode, "mode", "create and save the vault file with this octal mode, ignored on Windows")
+	fs.IntVar(&passwordFD, "password-fd", -1, "read master passwords from lines of this file descriptor")
+	fs.StringVar(&auditLog, "audit-log", "", "append the names of changed entries to this file, see PORTUNUS_AUDIT_LOG")
+	fs.BoolVar(&readOnlyFlag, "read-only", false, "refuse commands that change the vault, see PORTUNUS_READONLY")
+	fs.StringVar(&onChange, "on-change", "", "run this shell command after saving changes, see PORTUNUS_ON_CHANGE")
+	fs.DurationVar(&timeout, "prompt-timeout", 0, "fail if passwords or other input aren't given within this long, 0 to wait forever")
+	fs.BoolVar(&showVersion, "version", false, "print the version and exit")
+	fs.BoolVar(&showVersion, "v", false, "shorthand for -version")
+}
+
+func printNames(names []string, jsonOutput bool) error {
+	if jsonOutput {
+		return printJSON(names)
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// info prints an informational message to stderr, unless -quiet is set.
+func info(format string, args ...interface{}) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+// zero overwrites b, so that secrets don't linger in memory longer than
+// needed.
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// readOnly reports whether commands that change the vault are forbidden, by
+// -read-only or by PORTUNUS_READONLY being set to anything but a false value
+// such as 0 or false.
+func readOnly() bool {
+	if readOnlyFlag {
+		return true
+	}
+	env := os.Getenv("PORTUNUS_READONLY")
+	if env == "" {
+		return false
 	}
+	ro, err := strconv.ParseBool(env)
+	return ro || err != nil
+}
+
+// equalSecrets reports whether a and b are equal, in time independent of
+// their contents.
+func equalSecrets(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func printJSON(v interface{}) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+// parseFlags parses the flags in args, which may be interspersed with
+// positional arguments, and returns the positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return pos, nil
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
+
+func lengthFlag(fs *flag.FlagSet) *int {
+	length := fs.Int("length", gen.DefaultLength, "number of random bytes in the password")
+	fs.IntVar(length, "l", gen.DefaultLength, "shorthand for -length")
+	return length
+}
+
+func charsetFlags(fs *flag.FlagSet) *gen.Charset {
+	cs := new(gen.Charset)
+	fs.StringVar(&cs.Format, "format", "", "encode random bytes as base64url (the default), hex or base32")
+	fs.BoolVar(&cs.Letters, "letters", false, "use upper and lower case letters")
+	fs.BoolVar(&cs.Digits, "digits", false, "use digits")
+	fs.BoolVar(&cs.Symbols, "symbols", false, "use symbols")
+	fs.BoolVar(&cs.NoAmbiguous, "no-ambiguous", false, "leave out easily confused characters")
+	fs.IntVar(&cs.MinLower, "min-lower", 0, "use at least this many lower case letters")
+	fs.IntVar(&cs.MinUpper, "min-upper", 0, "use at least this many upper case letters")
+	fs.IntVar(&cs.MinDigits, "min-digits", 0, "use at least this many digits")
+	fs.IntVar(&cs.MinSymbols, "min-symbols", 0, "use at least this many symbols")
+	return cs
+}
+
+func passphraseFlags(fs *flag.FlagSet) *gen.Phrase {
+	pp := new(gen.Phrase)
+	fs.IntVar(&pp.Words, "words", 0, "generate a passphrase of this many words instead")
+	fs.StringVar(&pp.Separator, "separator", "-", "separator between passphrase words")
+	fs.BoolVar(&pp.Capitalise, "capitalise", false, "capitalise passphrase words")
+	fs.BoolVar(&pp.Digit, "digit", false, "add a digit to a passphrase word")
+	return pp
+}
+
+// generatorFlags defines the flags configuring generated passwords on fs, so
+// that every command generating them takes the same flags and gets the same
+// passwords. It returns a function that checks the flags and returns a
+// generator for passwords and their entropy in bits.
+func generatorFlags(fs *flag.FlagSet) func() (generate func() string, bits float64, err error) {
+	length := lengthFlag(fs)
+	cs := charsetFlags(fs)
+	pp := passphraseFlags(fs)
+	pattern := fs.String("pattern", "", "generate a character per token of this pattern instead, e.g. LLLLddss")
+	profile := fs.String("profile", "", "use the generator flags of this profile, see 'portunus help'")
+	return func() (func() string, float64, error) {
+		if *profile != "" {
+			if err := applyProfile(fs, *profile); err != nil {
+				return nil, 0, err
+			}
+		}
+		if *pattern != "" {
+			if pp.Words != 0 {
+				return nil, 0, errGenMode
+			}
+			if err := gen.CheckPattern(*pattern); err != nil {
+				return nil, 0, err
+			}
+			return func() string { return cs.Pattern(*pattern) }, cs.PatternEntropy(*pattern), nil
+		}
+		if pp.Words != 0 {
+			if err := gen.CheckWords(pp.Words); err != nil {
+				return nil, 0, err
+			}
+			return func() string { return gen.Passphrase(*pp) }, pp.Entropy(), nil
+		}
+		if err := gen.CheckLength(*length); err != nil {
+			return nil, 0, err
+		}
+		if err := cs.Check(*length); err != nil {
+			return nil, 0, err
+		}
+		return func() string { return gen.Password(*length, *cs) }, cs.Entropy(*length), nil
+	}
+}
+
+func metaFlags(fs *flag.FlagSet) *vault.Entry {
+	meta := new(vault.Entry)
+	fs.StringVar(&meta.Username, "username", "", "username for the entry")
+	fs.StringVar(&meta.Username, "u", "", "shorthand for -username")
+	fs.StringVar(&meta.URL, "url", "", "URL for the entry")
+	fs.StringVar(&meta.Notes, "notes", "", "free-form notes for the entry")
+	fs.Var((*stringsFlag)(&meta.Tags), "tag", "add this `tag` to the entry, can be repeated")
+	fs.DurationVar(&meta.RotateEvery, "rotate-every", 0, "remind to change the password this long after it was last changed, see 'portunus stale'")
+	return meta
+}
+
+// sinceFlag is a point in time given as a duration before now, which may be
+// in days or weeks, or as a date or RFC 3339 time
+type sinceFlag time.Time
+
+func (sf *sinceFlag) String() string {
+	if sf == nil || time.Time(*sf).IsZero() {
+		return ""
+	}
+	return time.Time(*sf).Format(time.RFC3339)
+}
+
+func (sf *sinceFlag) Set(s string) error {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			*sf = sinceFlag(t)
+			return nil
+		}
+	}
+	unit := time.Duration(1)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit, s = 24*time.Hour, strings.TrimSuffix(s, "d")
+	case strings.HasSuffix(s, "w"):
+		unit, s = 7*24*time.Hour, strings.TrimSuffix(s, "w")
+	}
+	var d time.Duration
+	if unit == 1 {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return errBadSince
+		}
+	} else {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return errBadSince
+		}
+		d = time.Duration(n) * unit
+	}
+	if d < 0 {
+		return errBadSince
+	}
+	*sf = sinceFlag(time.Now().Add(-d))
+	return nil
+}
+
+// stringsFlag is a flag that can be given several times
+type stringsFlag []string
+
+func (sf *stringsFlag) String() string {
+	if sf == nil {
+		return ""
+	}
+	return strings.Join(*sf, ",")
+}
+
+func (sf *stringsFlag) Set(s string) error {
+	*sf = append(*sf, s)
+	return nil
+}
+
+func strengthFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("strength", false, "print the estimated strength of the password")
+}
+
+func clipFlags(fs *flag.FlagSet) (clip *bool, timeout *time.Duration) {
+	clip = fs.Bool("clip", false, "copy the password to the clipboard instead of printing it")
+	fs.BoolVar(clip, "c", false, "shorthand for -clip")
+	timeout = fs.Duration("clip-timeout", defaultClipTimeout, "clear the clipboard after this long, 0 to never clear")
+	return clip, timeout
+}
+
+func outputFlag(fs *flag.FlagSet) *string {
+	output := fs.String("output", "", "write to this file, only its owner can access, instead")
+	fs.StringVar(output, "o", "", "shorthand for -output")
+	return output
+}
+
+func forceFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("force", false, "overwrite an existing value without asking")
+}
+
+// confirmOverwrite asks on the terminal whether the value of name may be
+// overwritten, unless force is set. Without a terminal it refuses.
+func confirmOverwrite(name string, force bool) error {
+	if force || confirm(fmt.Sprintf("overwrite '%s'?", name)) {
+		return nil
+	}
+	return errNotOverwritten
+}
+
+// confirm asks a yes or no question on the terminal, defaulting to no. It
+// returns false if stdin is not a terminal.
+func confirm(question string) bool {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	var answer string
+	fmt.Scanln(&answer)
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
+
+// exitCodes maps errors to exit statuses other than 1, so that scripts can
+// tell them apart
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{vault.ErrNoSuchValue, 2},
+	{vault.ErrNotExists, 3},
+	{vault.ErrInvalid, 3},
+	{vault.ErrEmpty, 3},
+	{vault.ErrVersion, 3},
+	{vault.ErrLocked, 4},
+}
+
+func exitCode(err error) int {
+	for _, ec := range exitCodes {
+		if errors.Is(err, ec.err) {
+			return ec.code
+		}
+	}
+	return 1
 }
 
 func chk(err error) {
 	if err != nil {
-		fmt.Fprintln(os.Stderr, fmt.Errorf("portunus: %w", err))
-		os.Exit(1)
+		printError(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// printError prints err to stderr, as JSON with its exit status if -json is
+// set.
+func printError(err error) {
+	if jsonOutput {
+		json.NewEncoder(os.Stderr).Encode(struct {
+			Error string `json:"error"`
+			Code  int    `json:"code"`
+		}{err.Error(), exitCode(err)})
+		return
 	}
+	fmt.Fprintln(os.Stderr, fmt.Errorf("portunus: %w", err))
 }
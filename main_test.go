@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEqualSecrets(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"", "", true},
+		{"hunter2", "hunter2", true},
+		{"hunter2", "hunter3", false},
+		{"hunter2", "hunter22", false},
+		{"hunter2", "", false},
+	}
+	for _, test := range tests {
+		if got := equalSecrets(test.a, test.b); got != test.want {
+			t.Errorf("equalSecrets(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSinceFlagTime(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Time
+	}{
+		{"2020-02-29", time.Date(2020, 2, 29, 0, 0, 0, 0, time.Local)},
+		{"2020-02-29T12:30:00Z", time.Date(2020, 2, 29, 12, 30, 0, 0, time.UTC)},
+		{"2020-02-29T12:30:00+01:00", time.Date(2020, 2, 29, 11, 30, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		var sf sinceFlag
+		if err := sf.Set(test.s); err != nil {
+			t.Errorf("Set(%q): %v", test.s, err)
+		} else if got := time.Time(sf); !got.Equal(test.want) {
+			t.Errorf("Set(%q) = %v, want %v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestSinceFlagDuration(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Duration
+	}{
+		{"36h", 36 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"0d", 0},
+		{"7d", 7 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+	}
+	for _, test := range tests {
+		var sf sinceFlag
+		before := time.Now()
+		if err := sf.Set(test.s); err != nil {
+			t.Errorf("Set(%q): %v", test.s, err)
+			continue
+		}
+		after := time.Now()
+		got := time.Time(sf)
+		if got.Before(before.Add(-test.want)) || got.After(after.Add(-test.want)) {
+			t.Errorf("Set(%q) = %v, want %v before now", test.s, got, test.want)
+		}
+	}
+}
+
+func TestSinceFlagInvalid(t *testing.T) {
+	for _, s := range []string{"", "x", "3y", "1.5d", "-1d", "-2h", "2020-02-30", "d"} {
+		var sf sinceFlag
+		if err := sf.Set(s); err != errBadSince {
+			t.Errorf("Set(%q) = %v, want %v", s, err, errBadSince)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/patrickmcnamara/portunus/vault"
+)
+
+var (
+	errNoProfile  = errors.New("no such generator profile")
+	errBadProfile = errors.New("profiles may only hold generator flags")
+)
+
+// profilesFile holds named sets of generator flags, one per line: a name
+// followed by flags, e.g. "strong -length 20 -symbols". Blank lines and lines
+// starting with # are ignored.
+func profilesFile() string {
+	return filepath.Join(vaultsDir(), "profiles")
+}
+
+// loadProfile returns the generator flags of the named profile.
+func loadProfile(name string) ([]string, error) {
+	fd, err := os.Open(profilesFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w '%s', define it in %s", errNoProfile, name, profilesFile())
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	sc := bufio.NewScanner(fd)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == name {
+			return fields[1:], nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w '%s' in %s", errNoProfile, name, profilesFile())
+}
+
+// applyProfile sets the generator flags of the named profile on fs, keeping
+// any flags given explicitly, which take precedence. Profiles may only hold
+// generator flags.
+func applyProfile(fs *flag.FlagSet, name string) error {
+	args, err := loadProfile(name)
+	if err != nil {
+		return err
+	}
+	if err := checkGeneratorArgs(args); err != nil {
+		return fmt.Errorf("profile '%s': %w", name, err)
+	}
+	return applyGeneratorArgs(fs, args)
+}
+
+// checkGeneratorArgs checks that args are only generator flags, without
+// -profile.
+func checkGeneratorArgs(args []string) error {
+	check := newFlagSet("generator")
+	generatorFlags(check)
+	if err := check.Parse(args); err != nil {
+		return err
+	}
+	if check.NArg() > 0 || check.Lookup("profile").Value.String() != "" {
+		return errBadProfile
+	}
+	return nil
+}
+
+// applyGeneratorArgs parses the generator flags args into fs, keeping any
+// generator flags already given, which take precedence. Only those are set
+// again, as setting a repeatable flag like -tag would add to it.
+func applyGeneratorArgs(fs *flag.FlagSet, args []string) error {
+	generator := newFlagSet("generator")
+	generatorFlags(generator)
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		if generator.Lookup(f.Name) != nil {
+			explicit[f.Name] = f.Value.String()
+		}
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generatorArgs returns the generator flags set on fs, with any profile
+// expanded, as arguments that applyGeneratorArgs accepts.
+func generatorArgs(fs *flag.FlagSet) []string {
+	generator := newFlagSet("generator")
+	generatorFlags(generator)
+	var args []string
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "profile" && generator.Lookup(f.Name) != nil {
+			args = append(args, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+		}
+	})
+	return args
+}
+
+// applyPolicy sets the generation policy stored with e on fs, unless other
+// generator flags or a profile were given, which replace it.
+func applyPolicy(fs *flag.FlagSet, e vault.Entry) error {
+	if len(e.Policy) == 0 || len(generatorArgs(fs)) > 0 || fs.Lookup("profile").Value.String() != "" {
+		return nil
+	}
+	if err := checkGeneratorArgs(e.Policy); err != nil {
+		return fmt.Errorf("generation policy of entry: %w", err)
+	}
+	return applyGeneratorArgs(fs, e.Policy)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/patrickmcnamara/portunus/vault"
+)
+
+// generatorTest has the generator and entry flags that 'new' and
+// 'set -generate' have.
+type generatorTest struct {
+	fs        *flag.FlagSet
+	generator func() (func() string, float64, error)
+	meta      *vault.Entry
+}
+
+func newGeneratorTest(t *testing.T, args ...string) generatorTest {
+	t.Helper()
+	fs := newFlagSet("new")
+	gt := generatorTest{fs: fs, generator: generatorFlags(fs), meta: metaFlags(fs)}
+	if _, err := parseFlags(fs, args); err != nil {
+		t.Fatal(err)
+	}
+	return gt
+}
+
+func (gt generatorTest) check(t *testing.T, tags []string, flags map[string]string) {
+	t.Helper()
+	if _, _, err := gt.generator(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gt.meta.Tags, tags) {
+		t.Errorf("tags = %q, want %q", gt.meta.Tags, tags)
+	}
+	for name, want := range flags {
+		if got := gt.fs.Lookup(name).Value.String(); got != want {
+			t.Errorf("-%s = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestProfileTags(t *testing.T) {
+	_, cleanup := tempConfigDir(t)
+	defer cleanup()
+	if err := os.MkdirAll(vaultsDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(profilesFile(), []byte("p -length 20 -symbols\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	gt := newGeneratorTest(t, "-profile", "p", "-tag", "work", "-tag", "bank", "-length", "30", "x")
+	gt.check(t, []string{"work", "bank"}, map[string]string{"length": "30", "symbols": "true"})
+}
+
+func TestPolicyTags(t *testing.T) {
+	gt := newGeneratorTest(t, "-tag", "work", "-tag", "bank", "x")
+	e := vault.Entry{Policy: []string{"-digits=true", "-length=12"}}
+	if err := applyPolicy(gt.fs, e); err != nil {
+		t.Fatal(err)
+	}
+	gt.check(t, []string{"work", "bank"}, map[string]string{"length": "12", "digits": "true"})
+	if got, want := generatorArgs(gt.fs), e.Policy; !reflect.DeepEqual(got, want) {
+		t.Errorf("policy = %q, want %q", got, want)
+	}
+}
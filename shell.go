@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/patrickmcnamara/portunus/vault"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var errUnclosedQuote = errors.New("unclosed quote")
+
+// shell reads commands from stdin and runs them on vlt until "exit", "quit"
+// or the end of input. Errors are printed rather than ending the shell.
+// Before each command, vlt is reloaded if its file has been changed by
+// another process, so that saving it doesn't silently undo those changes.
+// Clipboards the commands copied to are cleared in the background, which the
+// shell waits for before it returns.
+func shell(vlt *vault.Vault) error {
+	defer background.Wait()
+	tty := terminal.IsTerminal(int(os.Stdin.Fd()))
+	for {
+		if tty {
+			fmt.Fprint(os.Stderr, "portunus> ")
+		}
+		line, err := stdin.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		args, splitErr := splitLine(line)
+		switch {
+		case splitErr != nil:
+			printError(splitErr)
+		case len(args) == 0:
+		case args[0] == "exit" || args[0] == "quit":
+			return nil
+		default:
+			if err := runIn(vlt, args[0], args[1:]); err != nil {
+				printError(err)
+			}
+		}
+		if err == io.EOF {
+			if tty {
+				fmt.Fprintln(os.Stderr)
+			}
+			return nil
+		}
+	}
+}
+
+// splitLine splits line into words separated by spaces, where single or
+// double quotes group words, so that names with spaces can be given.
+func splitLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var inWord bool
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errUnclosedQuote
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// reloadVault reloads vlt if its file has changed since it was last read or
+// written.
+func reloadVault(vlt *vault.Vault) error {
+	if vaultFile == stdioVault {
+		return nil
+	}
+	reloaded, err := vlt.Reload()
+	if err != nil {
+		return fmt.Errorf("%w, restart the shell", err)
+	}
+	if reloaded {
+		info("vault changed on disk, reloaded")
+	}
+	return nil
+}
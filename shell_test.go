@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{" \t\r\n", nil},
+		{"get gmail\n", []string{"get", "gmail"}},
+		{"  get\t gmail  ", []string{"get", "gmail"}},
+		{`get "my bank"`, []string{"get", "my bank"}},
+		{`get 'my "bank"'`, []string{"get", `my "bank"`}},
+		{`get "it's"`, []string{"get", "it's"}},
+		{`get a"b c"d`, []string{"get", "ab cd"}},
+		{`set "" x`, []string{"set", "", "x"}},
+	}
+	for _, test := range tests {
+		got, err := splitLine(test.line)
+		if err != nil {
+			t.Errorf("splitLine(%q): %v", test.line, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitLine(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestSplitLineUnclosed(t *testing.T) {
+	for _, line := range []string{`get "my bank`, `get 'gmail`, `get "it's' `} {
+		if _, err := splitLine(line); err != errUnclosedQuote {
+			t.Errorf("splitLine(%q) = %v, want %v", line, err, errUnclosedQuote)
+		}
+	}
+}
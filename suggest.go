@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/patrickmcnamara/portunus/vault"
+)
+
+// most names suggested for a mistyped one
+const maxSuggestions = 3
+
+// getEntry follows any aliases from name and returns the name and entry they
+// lead to like vlt.Get but, if there is none, suggests the names closest to
+// name in the error, which still wraps vault.ErrNoSuchValue.
+func getEntry(vlt *vault.Vault, name string) (string, vault.Entry, error) {
+	target, err := vlt.Resolve(name)
+	if err == nil {
+		e, err := vlt.Get(target)
+		return target, e, err
+	}
+	if !errors.Is(err, vault.ErrNoSuchValue) {
+		return "", vault.Entry{}, err
+	}
+	if _, aliasErr := vlt.Get(name); aliasErr == nil {
+		return "", vault.Entry{}, fmt.Errorf("%w, '%s' is an alias of a missing entry", err, name)
+	}
+	if names := suggestNames(name, vlt.Names()); len(names) > 0 {
+		err = fmt.Errorf("%w, did you mean: %s?", err, strings.Join(names, ", "))
+	}
+	return "", vault.Entry{}, err
+}
+
+// suggestNames returns up to maxSuggestions of names within a few edits of
+// name, ignoring case, closest first.
+func suggestNames(name string, names []string) []string {
+	max := (len([]rune(name)) + 2) / 3
+	dists := make(map[string]int)
+	var matches []string
+	for _, n := range names {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(n)); d <= max {
+			dists[n] = d
+			matches = append(matches, n)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return dists[matches[i]] < dists[matches[j]]
+	})
+	if len(matches) > maxSuggestions {
+		matches = matches[:maxSuggestions]
+	}
+	return matches
+}
+
+// editDistance returns the Levenshtein distance between a and b: the number
+// of characters that must be inserted, deleted or substituted to turn a into
+// b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if d := cur[j-1] + 1; d < cur[j] {
+				cur[j] = d
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
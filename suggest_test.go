@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"gmail", "gmail", 0},
+		{"", "abc", 3},
+		{"gmial", "gmail", 2},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+	}
+	for _, test := range tests {
+		if got := editDistance(test.a, test.b); got != test.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSuggestNames(t *testing.T) {
+	names := []string{"bank", "github", "gitlab", "gmail", "GMX", "work/gmail"}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"gmial", []string{"gmail"}},
+		{"GMAIL", []string{"gmail"}},
+		{"gmx", []string{"GMX"}},
+		// closest first, and in the given order when as close
+		{"gitlab", []string{"gitlab", "github"}},
+		{"gitlub", []string{"github", "gitlab"}},
+		{"g", nil},
+		{"zzzzzz", nil},
+	}
+	for _, test := range tests {
+		if got := suggestNames(test.name, names); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("suggestNames(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSuggestNamesLimit(t *testing.T) {
+	names := []string{"mail1", "mail2", "mail3", "mail4"}
+	if got := suggestNames("mail", names); len(got) != maxSuggestions {
+		t.Errorf("suggestNames = %q, want %d names", got, maxSuggestions)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// ANSI escape sequences used to colour tables
+const (
+	ansiBold  = "\x1b[1m"
+	ansiCyan  = "\x1b[36m"
+	ansiReset = "\x1b[0m"
+)
+
+// printTable prints rows to stdout as columns aligned two spaces apart. On a
+// terminal a header line is printed first and, unless NO_COLOR is set, the
+// header and first column are coloured. Piped output is just the rows, so
+// that it stays easy to parse.
+func printTable(header []string, rows [][]string) error {
+	tty := terminal.IsTerminal(int(os.Stdout.Fd()))
+	color := tty && os.Getenv("NO_COLOR") == ""
+	if tty {
+		rows = append([][]string{header}, rows...)
+	}
+	widths := make([]int, len(header))
+	for _, row := range rows {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	for r, row := range rows {
+		var b strings.Builder
+		for i, cell := range row {
+			pad := ""
+			if i < len(row)-1 {
+				pad = strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2)
+			}
+			// escapes are added after measuring, as they take no space
+			switch {
+			case color && r == 0:
+				cell = ansiBold + cell + ansiReset
+			case color && i == 0:
+				cell = ansiCyan + cell + ansiReset
+			}
+			b.WriteString(cell + pad)
+		}
+		if _, err := fmt.Println(b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
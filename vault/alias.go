@@ -0,0 +1,100 @@
+package vault
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	ErrAliasCycle = errors.New("aliases lead back to themselves")
+	ErrIsAlias    = errors.New("entry is an alias, change its target or remove it first")
+)
+
+// SetAlias makes alias another name for the entry target, which may itself
+// be an alias. An existing alias is repointed, but other entries are never
+// replaced.
+func (vlt *Vault) SetAlias(alias, target string) error {
+	alias, target = NormName(alias), NormName(target)
+	if err := CheckName(alias); err != nil {
+		return err
+	}
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	if e, ok := vlt.entries[alias]; ok && e.Alias == "" {
+		return ErrValueExists
+	}
+	if _, ok := vlt.entries[target]; !ok {
+		return ErrNoSuchValue
+	}
+	name := target
+	for i := 0; name != "" && i <= len(vlt.entries); i++ {
+		if name == alias {
+			return ErrAliasCycle
+		}
+		name = vlt.entries[name].Alias
+	}
+	vlt.put(alias, Entry{Alias: target})
+	return nil
+}
+
+// Resolve follows aliases from name and returns the name of the entry they
+// lead to, which is name itself if it isn't an alias.
+func (vlt *Vault) Resolve(name string) (string, error) {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	return vlt.resolve(name)
+}
+
+// resolve is Resolve for callers that hold the lock.
+func (vlt *Vault) resolve(name string) (string, error) {
+	name = NormName(name)
+	for i := 0; i <= len(vlt.entries); i++ {
+		e, ok := vlt.entries[name]
+		if !ok {
+			return "", ErrNoSuchValue
+		}
+		if e.Alias == "" {
+			return name, nil
+		}
+		name = e.Alias
+	}
+	return "", ErrAliasCycle
+}
+
+// Aliases returns the target of every alias by name.
+func (vlt *Vault) Aliases() map[string]string {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	aliases := make(map[string]string)
+	for name, e := range vlt.entries {
+		if e.Alias != "" {
+			aliases[name] = e.Alias
+		}
+	}
+	return aliases
+}
+
+// AliasesTo returns the sorted aliases that lead to name, directly or through
+// other aliases.
+func (vlt *Vault) AliasesTo(name string) []string {
+	name = NormName(name)
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	return vlt.aliasesTo(name)
+}
+
+// aliasesTo is AliasesTo for callers that hold the lock.
+func (vlt *Vault) aliasesTo(name string) []string {
+	aliases := []string{}
+	for alias, e := range vlt.entries {
+		for i := 0; e.Alias != "" && i <= len(vlt.entries); i++ {
+			if e.Alias == name {
+				aliases = append(aliases, alias)
+				break
+			}
+			e = vlt.entries[e.Alias]
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
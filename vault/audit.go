@@ -0,0 +1,130 @@
+package vault
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// thresholds below which audits report a password by default
+const (
+	DefaultAuditLength  = 12
+	DefaultAuditEntropy = 60
+)
+
+// AuditReport groups the names of entries with bad passwords
+type AuditReport struct {
+	Short      []string   `json:"short"`
+	Duplicates [][]string `json:"duplicates"`
+	Weak       []string   `json:"weak"`
+}
+
+// Audit reports entries with passwords shorter than minLength characters,
+// passwords shared by several entries, and passwords with an estimated
+// entropy below minEntropy bits. Entries without a password are ignored.
+func (vlt *Vault) Audit(minLength int, minEntropy float64) AuditReport {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	rep := AuditReport{Short: []string{}, Duplicates: [][]string{}, Weak: []string{}}
+	byPswd := make(map[string][]string)
+	for name, e := range vlt.entries {
+		if e.Password == "" {
+			continue
+		}
+		if utf8.RuneCountInString(e.Password) < minLength {
+			rep.Short = append(rep.Short, name)
+		}
+		if EstimateEntropy(e.Password) < minEntropy {
+			rep.Weak = append(rep.Weak, name)
+		}
+		byPswd[e.Password] = append(byPswd[e.Password], name)
+	}
+	for _, names := range byPswd {
+		if len(names) > 1 {
+			sort.Strings(names)
+			rep.Duplicates = append(rep.Duplicates, names)
+		}
+	}
+	sort.Strings(rep.Short)
+	sort.Strings(rep.Weak)
+	sort.Slice(rep.Duplicates, func(i, j int) bool {
+		return rep.Duplicates[i][0] < rep.Duplicates[j][0]
+	})
+	return rep
+}
+
+// UsedBy returns the sorted names of the entries other than name with the
+// password pswd. Every password is compared, in time independent of its
+// contents, so timing doesn't reveal which entries match.
+func (vlt *Vault) UsedBy(pswd, name string) []string {
+	name = NormName(name)
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	var names []string
+	for n, e := range vlt.entries {
+		if subtle.ConstantTimeCompare([]byte(e.Password), []byte(pswd)) == 1 && n != name {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (rep AuditReport) String() string {
+	var b strings.Builder
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%s:\n", title)
+		for _, line := range lines {
+			fmt.Fprintf(&b, "\t%s\n", line)
+		}
+	}
+	section("short", rep.Short)
+	dups := make([]string, len(rep.Duplicates))
+	for i, names := range rep.Duplicates {
+		dups[i] = strings.Join(names, ", ")
+	}
+	section("duplicates", dups)
+	section("weak", rep.Weak)
+	return b.String()
+}
+
+// EstimateEntropy estimates the entropy of pswd in bits, assuming each
+// character was drawn at random from the character classes it uses. This
+// overestimates the strength of dictionary words and patterns.
+func EstimateEntropy(pswd string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range pswd {
+		switch {
+		case r > unicode.MaxASCII:
+			other = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	var pool int
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(utf8.RuneCountInString(pswd)) * math.Log2(float64(pool))
+}
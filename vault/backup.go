@@ -0,0 +1,104 @@
+package vault
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// number of backups kept next to the vault file
+	maxBackups = 5
+
+	// sorts lexically in time order
+	backupTimeFormat = "20060102T150405.000000000Z"
+)
+
+var ErrNoBackup = errors.New("no vault backup found")
+
+// backup copies the vault file at path to a timestamped backup in the same
+// directory, pruning the oldest backups beyond maxBackups. It does nothing if
+// there is no vault file yet.
+func backup(path string) error {
+	if err := copyBackup(path); err != nil {
+		return err
+	}
+	return pruneBackups(path)
+}
+
+// copyBackup copies the vault file at path to a timestamped backup, if there
+// is a vault file.
+func copyBackup(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	bak := path + "." + time.Now().UTC().Format(backupTimeFormat) + ".bak"
+	return writeFileAtomic(bak, data, 0600)
+}
+
+// pruneBackups removes the oldest backups of the vault file at path beyond
+// maxBackups.
+func pruneBackups(path string) error {
+	baks, err := Backups(path)
+	if err != nil {
+		return err
+	}
+	for len(baks) > maxBackups {
+		if err := os.Remove(baks[0]); err != nil {
+			return err
+		}
+		baks = baks[1:]
+	}
+	return nil
+}
+
+// Backups returns the paths of the backups of the vault file at path, oldest
+// first.
+func Backups(path string) ([]string, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var baks []string
+	for _, fi := range fis {
+		name := fi.Name()
+		if strings.HasPrefix(name, base+".") && strings.HasSuffix(name, ".bak") {
+			baks = append(baks, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(baks)
+	return baks, nil
+}
+
+// Restore replaces the vault file at path with its most recent backup. The
+// replaced vault file is itself backed up first, so restoring twice swaps
+// them back, and the backup is only moved into place once that succeeded.
+func Restore(path string) error {
+	baks, err := Backups(path)
+	if err != nil {
+		return err
+	}
+	if len(baks) == 0 {
+		return ErrNoBackup
+	}
+	latest := baks[len(baks)-1]
+	if err := copyBackup(path); err != nil {
+		return err
+	}
+	if err := renameFile(latest, path); err != nil {
+		return err
+	}
+	return pruneBackups(path)
+}
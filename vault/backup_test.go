@@ -0,0 +1,36 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "portunus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "portunus.json")
+	for _, data := range []string{"one", "two"} {
+		if err := backup(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"one", "two"} {
+		if err := Restore(path); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := ioutil.ReadFile(path); string(data) != want {
+			t.Errorf("restored %q, want %q", data, want)
+		}
+		if baks, _ := Backups(path); len(baks) != 1 {
+			t.Errorf("%d backups after restoring, want 1", len(baks))
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"strconv"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// argon2id parameters used to derive the vault key, unless the vault
+	// records its own
+	kdfTime    = 1
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+
+	saltSize = 16
+
+	// currentVersion is the version of the vault format written by Save.
+	// Earlier versions weren't recorded in the file: version 1 stored bare
+	// passwords, and later unversioned files store entry objects. Version 3
+	// records KDF parameters, and is only written for vaults that don't use
+	// the default ones, so that older versions of portunus can still read the
+	// others.
+	currentVersion = 3
+
+	// kdfVersion is the first version that records KDF parameters
+	kdfVersion = 3
+)
+
+// FormatVersion is the version of the vault format written by this package.
+const FormatVersion = currentVersion
+
+// sealedVault is the on-disk representation of an encrypted vault
+type sealedVault struct {
+	Version int        `json:"version,omitempty"`
+	KDF     *KDFParams `json:"kdf,omitempty"`
+	Salt    []byte     `json:"salt"`
+	Nonce   []byte     `json:"nonce"`
+	Data    []byte     `json:"data"`
+}
+
+// kdf returns the KDF parameters of sv.
+func (sv sealedVault) kdf() (KDFParams, error) {
+	if sv.KDF == nil {
+		return DefaultKDF, nil
+	}
+	if err := sv.KDF.Check(); err != nil {
+		return KDFParams{}, ErrInvalid
+	}
+	return *sv.KDF, nil
+}
+
+// versionData returns the additional data that authenticates the version of
+// a sealed vault, so that it can't be changed without the key.
+func versionData(version int) []byte {
+	if version == 0 {
+		return nil
+	}
+	return []byte("portunus vault version " + strconv.Itoa(version))
+}
+
+// decodeEntries decodes the entries of a vault of the given version,
+// migrating older formats to the current one.
+func decodeEntries(version int, data []byte) (map[string]Entry, error) {
+	entries := make(map[string]Entry)
+	if version != 0 {
+		err := json.Unmarshal(data, &entries)
+		normEntries(entries)
+		return entries, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	for name, msg := range raw {
+		var e Entry
+		if json.Unmarshal(msg, &e.Password) != nil {
+			if err := json.Unmarshal(msg, &e); err != nil {
+				return nil, err
+			}
+		}
+		entries[name] = e
+	}
+	normEntries(entries)
+	return entries, nil
+}
+
+// plainEntries returns the entries of data if it is an unencrypted vault
+// file, which the first versions of portunus wrote as a flat map of names to
+// passwords, and reports whether it is one.
+func plainEntries(data []byte) (map[string]Entry, bool) {
+	var sv sealedVault
+	if json.Unmarshal(data, &sv) == nil && (sv.Salt != nil || sv.Nonce != nil || sv.Data != nil) {
+		return nil, false
+	}
+	var pswds map[string]string
+	if json.Unmarshal(data, &pswds) != nil {
+		return nil, false
+	}
+	entries, err := decodeEntries(0, data)
+	return entries, err == nil
+}
+
+// normEntries renames entries whose names aren't normalized, as written
+// before names were, unless that would replace another entry, and normalizes
+// the targets of aliases.
+func normEntries(entries map[string]Entry) {
+	for name, e := range entries {
+		if e.Alias != "" {
+			e.Alias = NormName(e.Alias)
+			entries[name] = e
+		}
+		if n := NormName(name); n != name {
+			if _, ok := entries[n]; !ok {
+				delete(entries, name)
+				entries[n] = e
+			}
+		}
+	}
+}
+
+func newSalt() []byte {
+	salt := make([]byte, saltSize)
+	rand.Read(salt)
+	return salt
+}
+
+func deriveKey(pswd string, salt []byte, kdf KDFParams) []byte {
+	p := []byte(pswd)
+	defer zero(p)
+	return argon2.IDKey(p, salt, kdf.Time, kdf.Memory, kdf.Threads, chacha20poly1305.KeySize)
+}
+
+// zero overwrites b, so that secrets don't linger in memory longer than
+// needed.
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+func seal(key, salt []byte, kdf KDFParams, plaintext []byte) (sealedVault, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return sealedVault{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	sv := sealedVault{Version: kdfVersion - 1, Salt: salt, Nonce: nonce}
+	if kdf != DefaultKDF {
+		sv.Version, sv.KDF = kdfVersion, &kdf
+	}
+	sv.Data = aead.Seal(nil, nonce, plaintext, versionData(sv.Version))
+	return sv, nil
+}
+
+func unseal(key []byte, sv sealedVault) ([]byte, error) {
+	if sv.Version > currentVersion {
+		return nil, ErrVersion
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sv.Nonce) != aead.NonceSize() {
+		return nil, ErrInvalid
+	}
+	// a wrong master password gives a wrong key, which Open detects by
+	// checking the authentication tag in constant time, so timing reveals
+	// nothing about how close the password was
+	return aead.Open(nil, sv.Nonce, sv.Data, versionData(sv.Version))
+}
@@ -0,0 +1,153 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+func TestOpenPlaintext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "portunus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "portunus.json")
+	if err := ioutil.WriteFile(path, []byte(`{"gmail":"pw1","bank":"pw2"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	vlt, err := Open(path, password("pw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, err := vlt.Get("gmail"); err != nil || e.Password != "pw1" {
+		t.Errorf("Get(gmail) = %q, %v, want pw1", e.Password, err)
+	}
+	if err := vlt.Save(); err != nil {
+		t.Fatal(err)
+	}
+	vlt.Close()
+	if _, err := Open(path, password("wrong")); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Open saved vault with the wrong password: got %v, want %v", err, ErrInvalid)
+	}
+	vlt, err = Open(path, password("pw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vlt.Close()
+	if e, err := vlt.Get("bank"); err != nil || e.Password != "pw2" {
+		t.Errorf("Get(bank) after saving = %q, %v, want pw2", e.Password, err)
+	}
+}
+
+func TestDecodeEntries(t *testing.T) {
+	tests := []struct {
+		version int
+		data    string
+		want    map[string]Entry
+	}{
+		// version 1, unversioned, stored bare passwords
+		{0, `{"a":"pw1","b":"pw2"}`, map[string]Entry{"a": {Password: "pw1"}, "b": {Password: "pw2"}}},
+		// later unversioned files store entry objects, maybe among passwords
+		{0, `{"a":"pw1","b":{"password":"pw2","username":"me"}}`, map[string]Entry{"a": {Password: "pw1"}, "b": {Password: "pw2", Username: "me"}}},
+		{2, `{"a":{"password":"pw1","tags":["work"]}}`, map[string]Entry{"a": {Password: "pw1", Tags: []string{"work"}}}},
+		{currentVersion, `{}`, map[string]Entry{}},
+	}
+	for _, test := range tests {
+		got, err := decodeEntries(test.version, []byte(test.data))
+		if err != nil {
+			t.Errorf("decodeEntries(%d, %s): %v", test.version, test.data, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("decodeEntries(%d, %s) = %+v, want %+v", test.version, test.data, got, test.want)
+		}
+	}
+	if _, err := decodeEntries(0, []byte(`{"a":1}`)); err == nil {
+		t.Error("decodeEntries of a number as an entry didn't fail")
+	}
+}
+
+// TestOpenUnversioned opens a vault sealed by versions of portunus from
+// before the format was recorded, with bare passwords, and checks that saving
+// it upgrades it.
+func TestOpenUnversioned(t *testing.T) {
+	dir, err := ioutil.TempDir("", "portunus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "portunus.json")
+	salt := newSalt()
+	aead, err := chacha20poly1305.NewX(deriveKey("pw", salt, DefaultKDF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	data, err := json.Marshal(sealedVault{
+		Salt:  salt,
+		Nonce: nonce,
+		Data:  aead.Seal(nil, nonce, []byte(`{"gmail":"pw1"}`), nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		vlt, err := Open(path, password("pw"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e, err := vlt.Get("gmail"); err != nil || e.Password != "pw1" {
+			t.Errorf("Get(gmail) = %q, %v, want pw1", e.Password, err)
+		}
+		if err := vlt.Save(); err != nil {
+			t.Fatal(err)
+		}
+		vlt.Close()
+	}
+	data, _ = ioutil.ReadFile(path)
+	var sv sealedVault
+	if err := json.Unmarshal(data, &sv); err != nil || sv.Version == 0 {
+		t.Errorf("saved vault has version %d, %v, want it upgraded", sv.Version, err)
+	}
+}
+
+func TestNormEntries(t *testing.T) {
+	const nfc, nfd = "caf\u00e9", "cafe\u0301"
+	tests := []struct {
+		in, want map[string]Entry
+	}{
+		{
+			map[string]Entry{nfd: {Password: "1"}},
+			map[string]Entry{nfc: {Password: "1"}},
+		},
+		// a decomposed name isn't renamed over the normalized one
+		{
+			map[string]Entry{nfd: {Password: "1"}, nfc: {Password: "2"}},
+			map[string]Entry{nfd: {Password: "1"}, nfc: {Password: "2"}},
+		},
+		{
+			map[string]Entry{nfc: {Password: "1"}, "link": {Alias: nfd}},
+			map[string]Entry{nfc: {Password: "1"}, "link": {Alias: nfc}},
+		},
+	}
+	for _, test := range tests {
+		got := make(map[string]Entry)
+		for name, e := range test.in {
+			got[name] = e
+		}
+		normEntries(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("normEntries(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
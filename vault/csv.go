@@ -0,0 +1,87 @@
+package vault
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSVColumns maps entry fields to CSV header names
+type CSVColumns struct {
+	Name, Username, Password, URL, Notes string
+}
+
+// ImportCSV adds an entry for each row of CSV read from in, reading the
+// columns named by cols from its header row. Names already in the vault are
+// skipped. It returns how many entries were added and skipped. Nothing is
+// added if any row can't be read or has a name that isn't allowed.
+func (vlt *Vault) ImportCSV(in io.Reader, cols CSVColumns) (added, skipped int, err error) {
+	r := csv.NewReader(in)
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading CSV header: %w", err)
+	}
+	idx := make(map[string]int, len(header))
+	for i, col := range header {
+		idx[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+	column := func(col string, required bool) (int, error) {
+		i, ok := idx[strings.ToLower(col)]
+		if !ok {
+			if required {
+				return 0, fmt.Errorf("no '%s' column in CSV header", col)
+			}
+			return -1, nil
+		}
+		return i, nil
+	}
+	nameIdx, err := column(cols.Name, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	pswdIdx, err := column(cols.Password, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	userIdx, _ := column(cols.Username, false)
+	urlIdx, _ := column(cols.URL, false)
+	notesIdx, _ := column(cols.Notes, false)
+	field := func(rec []string, i int) string {
+		if i < 0 {
+			return ""
+		}
+		return rec[i]
+	}
+	var names []string
+	var entries []Entry
+	for line := 2; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		name := NormName(field(rec, nameIdx))
+		if err := CheckName(name); err != nil {
+			return 0, 0, fmt.Errorf("CSV line %d: %w: '%s'", line, err, name)
+		}
+		names = append(names, name)
+		entries = append(entries, Entry{
+			Password: field(rec, pswdIdx),
+			Username: field(rec, userIdx),
+			URL:      field(rec, urlIdx),
+			Notes:    field(rec, notesIdx),
+		})
+	}
+	for i, name := range names {
+		if vlt.Add(name, entries[i]) {
+			added++
+		} else {
+			skipped++
+		}
+	}
+	return added, skipped, nil
+}
@@ -0,0 +1,31 @@
+package vault
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestImportCSVBadName(t *testing.T) {
+	vlt, cleanup := testVault(t)
+	defer cleanup()
+	in := "name,password\nok,1\n padded,2\n"
+	if _, _, err := vlt.ImportCSV(strings.NewReader(in), CSVColumns{Name: "name", Password: "password"}); !errors.Is(err, ErrBadName) {
+		t.Errorf("ImportCSV = %v, want %v", err, ErrBadName)
+	}
+	if n := vlt.Len(); n != 0 {
+		t.Errorf("%d entries imported, want none", n)
+	}
+}
+
+func TestMergeBadName(t *testing.T) {
+	vlt, cleanup := testVault(t)
+	defer cleanup()
+	entries := map[string]Entry{"ok": {Password: "1"}, "bad\n": {Password: "2"}}
+	if _, _, err := vlt.Merge(entries, ImportSkip); !errors.Is(err, ErrBadName) {
+		t.Errorf("Merge = %v, want %v", err, ErrBadName)
+	}
+	if n := vlt.Len(); n != 0 {
+		t.Errorf("%d entries merged, want none", n)
+	}
+}
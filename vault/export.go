@@ -0,0 +1,176 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// ImportPolicy says how to import a name that is already in the vault
+type ImportPolicy int
+
+// import policies
+const (
+	ImportFail ImportPolicy = iota
+	ImportSkip
+	ImportOverwrite
+	ImportRename
+)
+
+var (
+	ErrImportCollision = errors.New("names already in vault")
+	ErrImportInvalid   = errors.New("invalid export file")
+)
+
+// Export writes every entry to path, either as plaintext JSON or encrypted
+// with the vault key in the same format as the vault file.
+func (vlt *Vault) Export(path string, plain bool) error {
+	data, err := vlt.ExportData(plain)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// ExportData returns what Export would write, for writing it elsewhere.
+func (vlt *Vault) ExportData(plain bool) ([]byte, error) {
+	if plain {
+		return json.MarshalIndent(vlt.Entries(), "", "\t")
+	}
+	return vlt.Seal()
+}
+
+// ReadExport reads the entries from a file written by Export, calling
+// password for its password if it is encrypted.
+func ReadExport(path string, password func() (string, error)) (map[string]Entry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var version int
+	var sv sealedVault
+	if json.Unmarshal(data, &sv) == nil && sv.Salt != nil && sv.Nonce != nil && sv.Data != nil {
+		version = sv.Version
+		pswd, err := password()
+		if err != nil {
+			return nil, err
+		}
+		kdf, err := sv.kdf()
+		if err != nil {
+			return nil, ErrImportInvalid
+		}
+		data, err = unseal(deriveKey(pswd, sv.Salt, kdf), sv)
+		if errors.Is(err, ErrVersion) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, ErrImportInvalid
+		}
+	}
+	entries, err := decodeEntries(version, data)
+	zero(data)
+	if err != nil {
+		return nil, ErrImportInvalid
+	}
+	return entries, nil
+}
+
+// Merge adds entries to the vault, resolving names already in the vault by
+// policy, and returns how many entries were added and skipped. Nothing is
+// added if any name isn't allowed.
+func (vlt *Vault) Merge(entries map[string]Entry, policy ImportPolicy) (added, skipped int, err error) {
+	normed := make(map[string]Entry, len(entries))
+	for name, e := range entries {
+		name = NormName(name)
+		if err := CheckName(name); err != nil {
+			return 0, 0, fmt.Errorf("%w: '%s'", err, name)
+		}
+		normed[name] = e
+	}
+	entries = normed
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	if policy == ImportFail {
+		for name := range entries {
+			if _, ok := vlt.entries[name]; ok {
+				return 0, 0, ErrImportCollision
+			}
+		}
+	}
+	for name, e := range entries {
+		if _, ok := vlt.entries[name]; ok {
+			switch policy {
+			case ImportSkip:
+				skipped++
+				continue
+			case ImportRename:
+				name = vlt.freeName(name)
+			}
+		}
+		vlt.entries[name] = e
+		added++
+	}
+	return added, skipped, nil
+}
+
+// Diff compares entries, such as from ReadExport, with the vault and returns
+// the sorted names only in entries, only in the vault, and in both but with
+// different values.
+func (vlt *Vault) Diff(entries map[string]Entry) (added, removed, changed []string, err error) {
+	added, removed, changed = []string{}, []string{}, []string{}
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	for name, e := range entries {
+		old, ok := vlt.entries[name]
+		if !ok {
+			added = append(added, name)
+			continue
+		}
+		same, err := sameEntry(old, e)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !same {
+			changed = append(changed, name)
+		}
+	}
+	for name := range vlt.entries {
+		if _, ok := entries[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed, nil
+}
+
+// sameEntry reports whether a and b encode the same, which unlike
+// reflect.DeepEqual doesn't tell nil and empty fields apart.
+func sameEntry(a, b Entry) (bool, error) {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+	defer zero(ja)
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+	defer zero(jb)
+	return bytes.Equal(ja, jb), nil
+}
+
+// freeName returns name with the lowest numeric suffix not already in the
+// vault. The caller must hold the lock.
+func (vlt *Vault) freeName(name string) string {
+	for i := 1; ; i++ {
+		alt := fmt.Sprintf("%s-%d", name, i)
+		if _, ok := vlt.entries[alt]; !ok {
+			return alt
+		}
+	}
+}
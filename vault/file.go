@@ -0,0 +1,79 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// errLockHeld is returned by lockFile if another process holds the lock
+var errLockHeld = errors.New("lock held")
+
+// writeFileAtomic writes data to a temporary file in the same directory as
+// path, syncs it and renames it over path, so that path always holds either
+// its old or its new contents, even if writing fails partway. On Windows
+// perm only decides whether the file is read-only.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	fd, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fd.Name())
+	defer fd.Close()
+	if _, err := fd.Write(data); err != nil {
+		return err
+	}
+	if err := fd.Chmod(perm); err != nil {
+		return err
+	}
+	if err := fd.Sync(); err != nil {
+		return err
+	}
+	if err := fd.Close(); err != nil {
+		return err
+	}
+	return renameFile(fd.Name(), path)
+}
+
+// Lock takes an exclusive lock on a lock file next to the vault file at path,
+// so that only one process at a time modifies the vault. It fails rather than
+// waits if another process holds the lock. The lock is released by calling
+// the returned function, or by the operating system when the process exits.
+// Missing directories leading to path are created, as when creating a vault.
+func Lock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("creating vault directory: %w", err)
+	}
+	fd, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(fd); err != nil {
+		fd.Close()
+		if err == errLockHeld {
+			err = storeErr(ErrLocked, &FileStore{Path: path})
+		}
+		return nil, err
+	}
+	return func() { fd.Close() }, nil
+}
+
+// CheckPerms refuses a file that users other than its owner can access, like
+// SSH does for private keys. Windows file modes don't reflect access
+// permissions, so it isn't checked there.
+func CheckPerms(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if perm := fi.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("%w, mode %04o at %s", ErrPerms, perm, path)
+	}
+	return nil
+}
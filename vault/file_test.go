@@ -0,0 +1,90 @@
+package vault
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCheckPerms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "portunus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "portunus.json")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		mode os.FileMode
+		err  error
+	}{
+		{0600, nil},
+		{0400, nil},
+		{0640, ErrPerms},
+		{0604, ErrPerms},
+	} {
+		if err := os.Chmod(path, test.mode); err != nil {
+			t.Fatal(err)
+		}
+		want := test.err
+		if runtime.GOOS == "windows" {
+			// file modes don't reflect access permissions, so any are fine
+			want = nil
+		}
+		if err := CheckPerms(path); !errors.Is(err, want) {
+			t.Errorf("CheckPerms with mode %04o = %v, want %v", test.mode, err, want)
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "portunus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "portunus.json")
+	for _, data := range []string{"one", "two"} {
+		if err := writeFileAtomic(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := ioutil.ReadFile(path); string(got) != data {
+			t.Errorf("file holds %q, want %q", got, data)
+		}
+	}
+	if fis, _ := ioutil.ReadDir(dir); len(fis) != 1 {
+		t.Errorf("%d files left, want 1", len(fis))
+	}
+}
+
+func TestLock(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "dragonfly", "freebsd", "linux", "netbsd", "openbsd", "windows":
+	default:
+		t.Skip("no file locks on", runtime.GOOS)
+	}
+	dir, err := ioutil.TempDir("", "portunus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sub", "portunus.json")
+	unlock, err := Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Lock(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("locking a locked vault = %v, want %v", err, ErrLocked)
+	}
+	unlock()
+	unlock, err = Lock(path)
+	if err != nil {
+		t.Fatalf("locking an unlocked vault: %v", err)
+	}
+	unlock()
+}
@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+)
+
+const (
+	// largest KDF memory accepted, in KiB, so that a damaged vault file can't
+	// make opening it exhaust memory
+	maxKDFMemory = 4 * 1024 * 1024
+
+	// largest KDF memory accepted over all iterations, in KiB, so that a
+	// damaged vault file can't make opening it take all but forever
+	maxKDFWork = 64 * 1024 * 1024
+)
+
+var ErrBadKDF = errors.New("KDF parameters need at least 1 iteration and thread, and 8 KiB of memory per thread, up to 4 GiB and 64 GiB over all iterations")
+
+// KDFParams are the argon2id parameters that derive the vault key from the
+// master password.
+type KDFParams struct {
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"` // in KiB
+	Threads uint8  `json:"threads"`
+}
+
+// DefaultKDF are the parameters of vaults that don't record their own.
+var DefaultKDF = KDFParams{Time: kdfTime, Memory: kdfMemory, Threads: kdfThreads}
+
+// Check reports whether p are usable KDF parameters.
+func (p KDFParams) Check() error {
+	if p.Time < 1 || p.Threads < 1 || p.Memory < 8*uint32(p.Threads) || p.Memory > maxKDFMemory {
+		return ErrBadKDF
+	}
+	if uint64(p.Time)*uint64(p.Memory) > maxKDFWork {
+		return ErrBadKDF
+	}
+	return nil
+}
+
+// CalibrateKDF returns KDF parameters with memory KiB and the default number
+// of threads, and as many iterations as take about target to derive a key on
+// this machine, but at least one and no more than Check allows.
+func CalibrateKDF(target time.Duration, memory uint32) (KDFParams, time.Duration, error) {
+	p := KDFParams{Time: 1, Memory: memory, Threads: kdfThreads}
+	if err := p.Check(); err != nil {
+		return KDFParams{}, 0, err
+	}
+	salt := newSalt()
+	took := timeKDF(salt, p)
+	// time enough iterations that the fixed cost of allocating the memory
+	// doesn't skew the estimate much
+	maxTime := maxKDFWork / memory
+	for took < target/2 && p.Time*2 <= maxTime {
+		p.Time *= 2
+		took = timeKDF(salt, p)
+	}
+	switch n := float64(p.Time) * float64(target) / float64(took); {
+	case n < 1:
+		p.Time = 1
+	case n > float64(maxTime):
+		p.Time = maxTime
+	default:
+		p.Time = uint32(n + 0.5)
+	}
+	return p, timeKDF(salt, p), nil
+}
+
+// timeKDF returns how long deriving a key with p takes.
+func timeKDF(salt []byte, p KDFParams) time.Duration {
+	start := time.Now()
+	zero(deriveKey("calibration", salt, p))
+	return time.Since(start)
+}
+
+// KDF returns the parameters the vault key is derived with.
+func (vlt *Vault) KDF() KDFParams {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	return vlt.kdf
+}
+
+// RekeyKDF is like Rekey, but also changes the KDF parameters the new key is
+// derived with.
+func (vlt *Vault) RekeyKDF(pswd string, p KDFParams) error {
+	if err := p.Check(); err != nil {
+		return err
+	}
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	vlt.kdf = p
+	vlt.salt = newSalt()
+	vlt.key = deriveKey(pswd, vlt.salt, vlt.kdf)
+	return nil
+}
+
+// CheckPassword reports whether pswd is the master password of the vault,
+// comparing the keys in constant time.
+func (vlt *Vault) CheckPassword(pswd string) bool {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	key := deriveKey(pswd, vlt.salt, vlt.kdf)
+	defer zero(key)
+	return subtle.ConstantTimeCompare(key, vlt.key) == 1
+}
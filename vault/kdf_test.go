@@ -0,0 +1,70 @@
+package vault
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKDFCheck(t *testing.T) {
+	tests := []struct {
+		p  KDFParams
+		ok bool
+	}{
+		{DefaultKDF, true},
+		{KDFParams{Time: 0, Memory: 64 * 1024, Threads: 4}, false},
+		{KDFParams{Time: 1, Memory: 64 * 1024, Threads: 0}, false},
+		{KDFParams{Time: 1, Memory: 16, Threads: 4}, false},
+		{KDFParams{Time: 1, Memory: maxKDFMemory + 1, Threads: 4}, false},
+		{KDFParams{Time: 1024, Memory: 64 * 1024, Threads: 4}, true},
+		{KDFParams{Time: 1025, Memory: 64 * 1024, Threads: 4}, false},
+		{KDFParams{Time: 1<<32 - 1, Memory: 8, Threads: 1}, false},
+	}
+	for _, test := range tests {
+		err := test.p.Check()
+		if test.ok && err != nil {
+			t.Errorf("%+v: %v", test.p, err)
+		}
+		if !test.ok && !errors.Is(err, ErrBadKDF) {
+			t.Errorf("%+v: got %v, want %v", test.p, err, ErrBadKDF)
+		}
+	}
+}
+
+func TestCalibrateKDF(t *testing.T) {
+	if _, _, err := CalibrateKDF(time.Millisecond, 4); !errors.Is(err, ErrBadKDF) {
+		t.Errorf("CalibrateKDF with 4 KiB: got %v, want %v", err, ErrBadKDF)
+	}
+	p, _, err := CalibrateKDF(10*time.Millisecond, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Memory != 64 || p.Threads != kdfThreads || p.Check() != nil {
+		t.Fatalf("CalibrateKDF = %+v, want usable parameters with 64 KiB", p)
+	}
+
+	vlt, cleanup := testVault(t)
+	defer cleanup()
+	vlt.Set("gmail", "hunter2", Entry{})
+	if err := vlt.RekeyKDF("new pw", p); err != nil {
+		t.Fatal(err)
+	}
+	if err := vlt.Save(); err != nil {
+		t.Fatal(err)
+	}
+	path := vlt.store.(*FileStore).Path
+	if _, err := Open(path, password("pw")); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Open with the old password: got %v, want %v", err, ErrInvalid)
+	}
+	opened, err := Open(path, password("new pw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer opened.Close()
+	if got := opened.KDF(); got != p {
+		t.Errorf("KDF after reopening = %+v, want %+v", got, p)
+	}
+	if e, err := opened.Get("gmail"); err != nil || e.Password != "hunter2" {
+		t.Errorf("Get after reopening = %q, %v, want %q", e.Password, err, "hunter2")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package vault
+
+import "os"
+
+// lockFile does nothing on platforms without advisory file locks.
+func lockFile(fd *os.File) error {
+	return nil
+}
@@ -0,0 +1,77 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+)
+
+// ErrRekeyed is returned by Reload if the stored vault is no longer
+// encrypted with the vault's master password.
+var ErrRekeyed = errors.New("vault was replaced or its master password changed")
+
+// Reload reloads the vault from its store if it has changed there since it
+// was opened or last saved, and reports whether it had. Unsaved changes to
+// the vault are kept over the stored entries, so saving it afterwards only
+// overwrites the entries changed here.
+func (vlt *Vault) Reload() (bool, error) {
+	data, err := vlt.store.Load()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, storeErr(ErrNotExists, vlt.store)
+		}
+		return false, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return false, storeErr(ErrEmpty, vlt.store)
+	}
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	if vlt.key == nil {
+		return false, ErrClosed
+	}
+	sum := sha256.Sum256(data)
+	if sum == vlt.sum {
+		return false, nil
+	}
+	var sv sealedVault
+	if err := json.Unmarshal(data, &sv); err != nil {
+		return false, storeErr(ErrInvalid, vlt.store)
+	}
+	if !bytes.Equal(sv.Salt, vlt.salt) {
+		return false, storeErr(ErrRekeyed, vlt.store)
+	}
+	data, err = unseal(vlt.key, sv)
+	if errors.Is(err, ErrVersion) {
+		return false, storeErr(err, vlt.store)
+	}
+	if err != nil {
+		return false, storeErr(ErrRekeyed, vlt.store)
+	}
+	stored, err := decodeEntries(sv.Version, data)
+	zero(data)
+	if err != nil {
+		return false, storeErr(ErrInvalid, vlt.store)
+	}
+	entries := make(map[string]Entry, len(stored))
+	for name, e := range stored {
+		entries[name] = e
+	}
+	for name, e := range vlt.entries {
+		if old, ok := vlt.saved[name]; !ok || !reflect.DeepEqual(old, e) {
+			entries[name] = e
+		}
+	}
+	for name := range vlt.saved {
+		if _, ok := vlt.entries[name]; !ok {
+			delete(entries, name)
+		}
+	}
+	vlt.entries = entries
+	vlt.saved = stored
+	vlt.sum = sum
+	return true, nil
+}
@@ -0,0 +1,93 @@
+package vault
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// passwords returns the passwords of the entries in vlt by name.
+func passwords(vlt *Vault) map[string]string {
+	pswds := make(map[string]string)
+	for name, e := range vlt.Entries() {
+		pswds[name] = e.Password
+	}
+	return pswds
+}
+
+func TestReload(t *testing.T) {
+	vlt, cleanup := testVault(t)
+	defer cleanup()
+	for _, name := range []string{"kept", "changed", "removed", "local"} {
+		vlt.Set(name, name, Entry{})
+	}
+	if err := vlt.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := vlt.Reload(); reloaded || err != nil {
+		t.Errorf("Reload of an unchanged vault = %v, %v, want false, nil", reloaded, err)
+	}
+
+	other, err := Open(vlt.store.(*FileStore).Path, password("pw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	other.Set("changed", "theirs", Entry{})
+	other.Set("local", "theirs", Entry{})
+	other.Remove("removed")
+	other.Set("added", "theirs", Entry{})
+	if err := other.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	vlt.Set("local", "ours", Entry{})
+	vlt.Set("new", "ours", Entry{})
+	vlt.Remove("kept")
+	reloaded, err := vlt.Reload()
+	if !reloaded || err != nil {
+		t.Fatalf("Reload of a changed vault = %v, %v, want true, nil", reloaded, err)
+	}
+	want := map[string]string{
+		"changed": "theirs",
+		"local":   "ours",
+		"added":   "theirs",
+		"new":     "ours",
+	}
+	if got := passwords(vlt); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries after Reload = %v, want %v", got, want)
+	}
+	if added, changed, removed := vlt.Changes(); !reflect.DeepEqual(added, []string{"new"}) ||
+		!reflect.DeepEqual(changed, []string{"local"}) || !reflect.DeepEqual(removed, []string{"kept"}) {
+		t.Errorf("Changes after Reload = %v, %v, %v, want [new], [local], [kept]", added, changed, removed)
+	}
+	if reloaded, err := vlt.Reload(); reloaded || err != nil {
+		t.Errorf("second Reload = %v, %v, want false, nil", reloaded, err)
+	}
+}
+
+func TestReloadRekeyed(t *testing.T) {
+	vlt, cleanup := testVault(t)
+	defer cleanup()
+	other, err := Open(vlt.store.(*FileStore).Path, password("pw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	other.Rekey("new pw")
+	if err := other.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := vlt.Reload(); reloaded || !errors.Is(err, ErrRekeyed) {
+		t.Errorf("Reload of a rekeyed vault = %v, %v, want false, %v", reloaded, err, ErrRekeyed)
+	}
+}
+
+func TestReloadClosed(t *testing.T) {
+	vlt, cleanup := testVault(t)
+	defer cleanup()
+	vlt.Close()
+	if _, err := vlt.Reload(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Reload of a closed vault: got %v, want %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package vault
+
+import "os"
+
+// renameFile atomically replaces newpath with oldpath.
+func renameFile(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
@@ -0,0 +1,33 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	errorAccessDenied     syscall.Errno = 5
+	errorSharingViolation syscall.Errno = 32
+
+	// attempts at renaming a file before giving up
+	renameAttempts = 10
+)
+
+// renameFile renames oldpath to newpath, replacing newpath. Unlike on Unix,
+// renaming over a file fails while another process, such as a virus scanner
+// or search indexer, briefly has it open, so this retries for a while.
+func renameFile(oldpath, newpath string) error {
+	var err error
+	for i := 0; i < renameAttempts; i++ {
+		if err = os.Rename(oldpath, newpath); err == nil {
+			return nil
+		}
+		if !errors.Is(err, errorAccessDenied) && !errors.Is(err, errorSharingViolation) {
+			return err
+		}
+		time.Sleep(time.Duration(i+1) * 20 * time.Millisecond)
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package vault
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRenameFileRetries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "portunus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldpath, newpath := filepath.Join(dir, "new"), filepath.Join(dir, "portunus.json")
+	for path, data := range map[string]string{oldpath: "new", newpath: "old"} {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// an open file can't be renamed over, as when a virus scanner has it
+	// open, until it is closed
+	fd, err := os.Open(newpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := time.AfterFunc(100*time.Millisecond, func() { fd.Close() })
+	defer closed.Stop()
+	if err := renameFile(oldpath, newpath); err != nil {
+		t.Fatalf("renameFile over a file that is closed meanwhile: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(newpath); string(data) != "new" {
+		t.Errorf("file holds %q, want new", data)
+	}
+}
+
+func TestRenameFileMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "portunus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	start := time.Now()
+	err = renameFile(filepath.Join(dir, "missing"), filepath.Join(dir, "portunus.json"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("renameFile of a missing file = %v, want %v", err, os.ErrNotExist)
+	}
+	// only sharing errors are retried
+	if took := time.Since(start); took > 20*time.Millisecond {
+		t.Errorf("renameFile of a missing file took %v, want no retries", took)
+	}
+}
@@ -0,0 +1,51 @@
+package vault
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Store keeps a sealed vault somewhere, such as in a file. Stores should
+// implement fmt.Stringer to describe where, for error messages.
+type Store interface {
+	// Load returns the sealed vault, or an error wrapping os.ErrNotExist if
+	// there is none yet.
+	Load() ([]byte, error)
+
+	// Save replaces the sealed vault with data, atomically if possible.
+	Save(data []byte) error
+}
+
+// FileStore keeps a sealed vault in the file at Path, backing it up before
+// every save.
+type FileStore struct {
+	Path string
+	Mode os.FileMode // permissions of the vault file, 0600 if zero, ignored on Windows
+}
+
+func (fs *FileStore) String() string {
+	return fs.Path
+}
+
+// Load reads the vault file.
+func (fs *FileStore) Load() ([]byte, error) {
+	return ioutil.ReadFile(fs.Path)
+}
+
+// Save backs up the vault file and atomically replaces it with data,
+// creating any missing directories leading to it.
+func (fs *FileStore) Save(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(fs.Path), 0700); err != nil {
+		return fmt.Errorf("creating vault directory: %w", err)
+	}
+	if err := backup(fs.Path); err != nil {
+		return err
+	}
+	mode := fs.Mode
+	if mode == 0 {
+		mode = 0600
+	}
+	return writeFileAtomic(fs.Path, data, mode)
+}
@@ -0,0 +1,61 @@
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// RFC 6238 defaults, as used by practically every authenticator
+	totpStep   = 30 * time.Second
+	totpDigits = 6
+)
+
+var (
+	ErrTOTPInvalid = errors.New("invalid TOTP secret, expected base32")
+	ErrTOTPMissing = errors.New("no TOTP secret for entry")
+)
+
+// NormaliseTOTPSecret cleans up a base32 secret as commonly displayed by
+// websites, with spaces, lower case letters and no padding, and checks that it
+// decodes.
+func NormaliseTOTPSecret(secret string) (string, error) {
+	secret = strings.ToUpper(strings.Join(strings.Fields(secret), ""))
+	secret = strings.TrimRight(secret, "=")
+	if secret == "" {
+		return "", ErrTOTPInvalid
+	}
+	if _, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret); err != nil {
+		return "", ErrTOTPInvalid
+	}
+	return secret, nil
+}
+
+// TOTP returns the code for secret at t and how long it remains valid.
+func TOTP(secret string, t time.Time) (string, time.Duration, error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return "", 0, ErrTOTPInvalid
+	}
+	counter := uint64(t.Unix()) / uint64(totpStep/time.Second)
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	// dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0xf
+	code := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	next := time.Unix(int64(counter+1)*int64(totpStep/time.Second), 0)
+	return fmt.Sprintf("%0*d", totpDigits, code%mod), next.Sub(t), nil
+}
@@ -0,0 +1,51 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTOTP checks the SHA-1 test vectors of RFC 6238, appendix B, of which
+// the codes here are the last 6 digits.
+func TestTOTP(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+	tests := []struct {
+		unix int64
+		code string
+		left time.Duration
+	}{
+		{59, "287082", time.Second},
+		{1111111109, "081804", time.Second},
+		{1111111111, "050471", 29 * time.Second},
+		{1234567890, "005924", 30 * time.Second},
+		{2000000000, "279037", 10 * time.Second},
+		{20000000000, "353130", 10 * time.Second},
+	}
+	for _, test := range tests {
+		code, left, err := TOTP(secret, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code || left != test.left {
+			t.Errorf("TOTP at %d = %s, %v, want %s, %v", test.unix, code, left, test.code, test.left)
+		}
+	}
+}
+
+func TestNormaliseTOTPSecret(t *testing.T) {
+	tests := []struct {
+		in, want string
+		err      error
+	}{
+		{"gezd gnbv gy3t qojq", "GEZDGNBVGY3TQOJQ", nil},
+		{"GEZDGNBVGY3TQOJQ====", "GEZDGNBVGY3TQOJQ", nil},
+		{"", "", ErrTOTPInvalid},
+		{"not base32!", "", ErrTOTPInvalid},
+	}
+	for _, test := range tests {
+		got, err := NormaliseTOTPSecret(test.in)
+		if got != test.want || err != test.err {
+			t.Errorf("NormaliseTOTPSecret(%q) = %q, %v, want %q, %v", test.in, got, err, test.want, test.err)
+		}
+	}
+}
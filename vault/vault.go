@@ -0,0 +1,702 @@
+// Package vault reads and writes portunus vaults: files of named credentials
+// encrypted with a key derived from a master password.
+package vault
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// longest allowed entry name, in bytes
+	maxNameLength = 256
+
+	// number of previous passwords kept per entry
+	maxHistory = 10
+)
+
+var (
+	ErrExists      = errors.New("vault file already exists")
+	ErrNotExists   = errors.New("no vault file found")
+	ErrInvalid     = errors.New("invalid vault file")
+	ErrEmpty       = errors.New("vault file is empty")
+	ErrNoSuchValue = errors.New("no such value in vault")
+	ErrValueExists = errors.New("value already exists in vault")
+	ErrLocked      = errors.New("vault is in use by another portunus process")
+	ErrPerms       = errors.New("vault file is accessible by other users")
+	ErrClosed      = errors.New("vault is closed")
+	ErrVersion     = errors.New("vault file is from a newer version of portunus")
+	ErrNoHistory   = errors.New("no such previous password for entry")
+	ErrBadRotate   = errors.New("rotation interval must not be negative")
+	ErrBadName     = fmt.Errorf("entry names must be non-empty, at most %d bytes, without control characters or surrounding spaces", maxNameLength)
+)
+
+// Entry is a set of credentials stored under a name in the vault
+type Entry struct {
+	Password string   `json:"password"`
+	Username string   `json:"username,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	Notes    string   `json:"notes,omitempty"`
+	TOTP     string   `json:"totp,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+
+	// how often the password should be changed, zero for never
+	RotateEvery time.Duration `json:"rotate_every,omitempty"`
+
+	// how the password was generated, as 'portunus gen' flags, so that it
+	// can be replaced by one generated the same way
+	Policy []string `json:"policy,omitempty"`
+
+	// name of the entry this is another name for, see SetAlias
+	Alias string `json:"alias,omitempty"`
+
+	// zero in entries from older vaults
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+
+	// contents of a small file, such as a key file
+	File []byte `json:"file,omitempty"`
+
+	// previous passwords, most recent first
+	History []OldPassword `json:"history,omitempty"`
+}
+
+// OldPassword is a password that has been replaced
+type OldPassword struct {
+	Password string    `json:"password"`
+	Replaced time.Time `json:"replaced"`
+}
+
+// setPassword replaces the password of e, keeping the old one in its history.
+func (e *Entry) setPassword(pswd string) {
+	if e.Password != "" && subtle.ConstantTimeCompare([]byte(e.Password), []byte(pswd)) == 0 {
+		old := OldPassword{Password: e.Password, Replaced: time.Now().UTC()}
+		e.History = append([]OldPassword{old}, e.History...)
+		if len(e.History) > maxHistory {
+			e.History = e.History[:maxHistory]
+		}
+	}
+	e.Password = pswd
+}
+
+// update sets the metadata of e to the non-empty metadata in meta.
+func (e *Entry) update(meta Entry) {
+	if meta.Username != "" {
+		e.Username = meta.Username
+	}
+	if meta.URL != "" {
+		e.URL = meta.URL
+	}
+	if meta.Notes != "" {
+		e.Notes = meta.Notes
+	}
+	for _, tag := range meta.Tags {
+		if !e.HasTag(tag) {
+			e.Tags = append(e.Tags, tag)
+		}
+	}
+	if meta.RotateEvery != 0 {
+		e.RotateEvery = meta.RotateEvery
+	}
+	if len(meta.Policy) > 0 {
+		e.Policy = meta.Policy
+	}
+}
+
+// Stale reports whether e should have been rotated by now, because it has a
+// rotation interval and was last modified longer ago than that.
+func (e Entry) Stale(now time.Time) bool {
+	return e.RotateEvery > 0 && now.After(e.Modified.Add(e.RotateEvery))
+}
+
+// HasTag reports whether e is tagged with tag, ignoring case.
+func (e Entry) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Vault is an open vault. Its methods are safe for concurrent use, and change
+// it only in memory until it is saved.
+type Vault struct {
+	store   Store
+	entries map[string]Entry
+	key     []byte
+	salt    []byte
+	kdf     KDFParams
+	lock    sync.Mutex
+
+	// saved is a copy of entries as read from the vault file, to report
+	// unsaved changes
+	saved map[string]Entry
+
+	// sum is the hash of the sealed vault as last loaded or saved, to notice
+	// changes made to it by others
+	sum [sha256.Size]byte
+}
+
+// Create creates an empty vault file at path, and any missing directories
+// leading to it, encrypted with the master password returned by password,
+// which is only called if there is no file at path yet.
+func Create(path string, password func() (string, error)) (*Vault, error) {
+	return CreateStore(&FileStore{Path: path}, password)
+}
+
+// CreateStore creates an empty vault in st, encrypted with the master
+// password returned by password, which is only called if st holds no vault
+// yet.
+func CreateStore(st Store, password func() (string, error)) (*Vault, error) {
+	if _, err := st.Load(); !errors.Is(err, os.ErrNotExist) {
+		if err != nil {
+			return nil, err
+		}
+		return nil, storeErr(ErrExists, st)
+	}
+	pswd, err := password()
+	if err != nil {
+		return nil, err
+	}
+	vlt := &Vault{store: st, entries: make(map[string]Entry), salt: newSalt(), kdf: DefaultKDF}
+	vlt.key = deriveKey(pswd, vlt.salt, vlt.kdf)
+	if err := vlt.Save(); err != nil {
+		return nil, err
+	}
+	return vlt, nil
+}
+
+// Open reads and decrypts the vault file at path with the master password
+// returned by password, which is only called once the file has been read.
+func Open(path string, password func() (string, error)) (*Vault, error) {
+	return OpenStore(&FileStore{Path: path}, password)
+}
+
+// OpenStore loads and decrypts the vault in st with the master password
+// returned by password, which is only called once the vault has been loaded.
+func OpenStore(st Store, password func() (string, error)) (*Vault, error) {
+	return openStore(st, func(salt []byte, kdf KDFParams) ([]byte, error) {
+		pswd, err := password()
+		if err != nil {
+			return nil, err
+		}
+		return deriveKey(pswd, salt, kdf), nil
+	})
+}
+
+// OpenStoreKey is like OpenStore, but decrypts the vault with the key
+// returned by key for the salt of the vault, such as one saved from Key, which
+// skips deriving it from the master password.
+func OpenStoreKey(st Store, key func(salt []byte) ([]byte, error)) (*Vault, error) {
+	return openStore(st, func(salt []byte, _ KDFParams) ([]byte, error) {
+		return key(salt)
+	})
+}
+
+// openStore opens the vault in st with the key returned by key for its salt
+// and KDF parameters. An unencrypted vault file from the first versions of
+// portunus is read as it is, and encrypted with the key when it is saved.
+func openStore(st Store, key func(salt []byte, kdf KDFParams) ([]byte, error)) (*Vault, error) {
+	vlt := &Vault{store: st, entries: make(map[string]Entry)}
+	data, err := st.Load()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, storeErr(ErrNotExists, st)
+		}
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		// left by an interrupted write from before saves were atomic
+		return nil, storeErr(ErrEmpty, st)
+	}
+	vlt.sum = sha256.Sum256(data)
+	if entries, ok := plainEntries(data); ok {
+		// encrypted with a new salt once it is saved
+		vlt.salt, vlt.kdf = newSalt(), DefaultKDF
+		if vlt.key, err = key(vlt.salt, vlt.kdf); err != nil {
+			return nil, err
+		}
+		vlt.entries = entries
+		vlt.saved = vlt.Entries()
+		return vlt, nil
+	}
+	var sv sealedVault
+	err = json.Unmarshal(data, &sv)
+	if err != nil {
+		return nil, storeErr(ErrInvalid, st)
+	}
+	vlt.salt = sv.Salt
+	if vlt.kdf, err = sv.kdf(); err != nil {
+		return nil, storeErr(err, st)
+	}
+	vlt.key, err = key(vlt.salt, vlt.kdf)
+	if err != nil {
+		return nil, err
+	}
+	data, err = unseal(vlt.key, sv)
+	if errors.Is(err, ErrVersion) {
+		return nil, storeErr(err, st)
+	}
+	if err != nil {
+		return nil, storeErr(ErrInvalid, st)
+	}
+	vlt.entries, err = decodeEntries(sv.Version, data)
+	zero(data)
+	if err != nil {
+		return nil, storeErr(ErrInvalid, st)
+	}
+	vlt.saved = vlt.Entries()
+	return vlt, nil
+}
+
+// Store returns where the vault is kept.
+func (vlt *Vault) Store() Store {
+	return vlt.store
+}
+
+// Key returns copies of the vault key and the salt it was derived with.
+func (vlt *Vault) Key() (key, salt []byte) {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	return append([]byte(nil), vlt.key...), append([]byte(nil), vlt.salt...)
+}
+
+// Rekey replaces the vault key with one derived from a new master password
+// and salt. The vault file is unchanged until it is saved.
+func (vlt *Vault) Rekey(pswd string) {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	vlt.salt = newSalt()
+	vlt.key = deriveKey(pswd, vlt.salt, vlt.kdf)
+}
+
+// Close zeroes the vault key and stored files and drops the entries, after
+// which the vault is empty and can't be saved. Go strings can't be
+// overwritten, so passwords stay in memory until they are garbage collected,
+// but Close stops them being reachable through the vault.
+func (vlt *Vault) Close() {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	for _, e := range vlt.entries {
+		zero(e.File)
+	}
+	zero(vlt.key)
+	vlt.key = nil
+	vlt.entries = make(map[string]Entry)
+	vlt.saved = nil
+}
+
+// storeErr annotates a vault error with where the vault is kept.
+func storeErr(err error, st Store) error {
+	return fmt.Errorf("%w at %v", err, st)
+}
+
+// Save replaces the vault in its store with the vault, and marks every entry
+// as saved.
+func (vlt *Vault) Save() error {
+	vlt.lock.Lock()
+	if vlt.key == nil {
+		vlt.lock.Unlock()
+		return ErrClosed
+	}
+	data, err := vlt.seal()
+	saved := make(map[string]Entry, len(vlt.entries))
+	for name, e := range vlt.entries {
+		saved[name] = e
+	}
+	vlt.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := vlt.store.Save(data); err != nil {
+		return err
+	}
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	vlt.sum = sha256.Sum256(data)
+	vlt.saved = saved
+	return nil
+}
+
+// Changes returns the sorted names of the entries that saving the vault would
+// add, change and remove.
+func (vlt *Vault) Changes() (added, changed, removed []string) {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	for name, e := range vlt.entries {
+		old, ok := vlt.saved[name]
+		switch {
+		case !ok:
+			added = append(added, name)
+		case !reflect.DeepEqual(old, e):
+			changed = append(changed, name)
+		}
+	}
+	for name := range vlt.saved {
+		if _, ok := vlt.entries[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return added, changed, removed
+}
+
+// Seal returns the vault encrypted in the vault file format.
+func (vlt *Vault) Seal() ([]byte, error) {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	return vlt.seal()
+}
+
+// seal is Seal for callers that hold the lock.
+func (vlt *Vault) seal() ([]byte, error) {
+	data, err := json.Marshal(vlt.entries)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(data)
+	sv, err := seal(vlt.key, vlt.salt, vlt.kdf, data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sv)
+}
+
+// NormName returns name in Unicode normalization form C, which the vault
+// stores and looks up names in, so that names that look the same, such as
+// with an accent as one character or as a letter and a combining accent, are
+// the same name.
+func NormName(name string) string {
+	return norm.NFC.String(name)
+}
+
+// CheckName reports whether name is allowed as an entry name.
+func CheckName(name string) error {
+	if name == "" || len(name) > maxNameLength || strings.TrimSpace(name) != name {
+		return ErrBadName
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return ErrBadName
+	}
+	return nil
+}
+
+// Set stores pswd under name, along with the non-empty metadata in meta, and
+// reports whether that changed the entry. An entry left as it was keeps its
+// modification time.
+func (vlt *Vault) Set(name, pswd string, meta Entry) (bool, error) {
+	name = NormName(name)
+	if err := CheckName(name); err != nil {
+		return false, err
+	}
+	if meta.RotateEvery < 0 {
+		return false, ErrBadRotate
+	}
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	old, ok := vlt.entries[name]
+	if old.Alias != "" {
+		return false, ErrIsAlias
+	}
+	e := old
+	e.Tags = append([]string(nil), old.Tags...)
+	e.update(meta)
+	e.setPassword(pswd)
+	if ok && reflect.DeepEqual(e, old) {
+		return false, nil
+	}
+	vlt.put(name, e)
+	return true, nil
+}
+
+// Rollback makes the ith previous password of name, counting from 0 for the
+// most recent, its password again. The replaced password is kept in the
+// history, so a rollback can itself be rolled back.
+func (vlt *Vault) Rollback(name string, i int) error {
+	name = NormName(name)
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	e, ok := vlt.entries[name]
+	if !ok {
+		return ErrNoSuchValue
+	}
+	if i < 0 || i >= len(e.History) {
+		return ErrNoHistory
+	}
+	pswd := e.History[i].Password
+	e.History = append(e.History[:i:i], e.History[i+1:]...)
+	e.setPassword(pswd)
+	vlt.put(name, e)
+	return nil
+}
+
+// SetAll stores each password in pswds under its name, either all of them or,
+// if any name is not allowed, none of them.
+func (vlt *Vault) SetAll(pswds map[string]string) error {
+	normed := make(map[string]string, len(pswds))
+	for name, pswd := range pswds {
+		normed[NormName(name)] = pswd
+	}
+	pswds = normed
+	for name := range pswds {
+		if err := CheckName(name); err != nil {
+			return fmt.Errorf("%w: '%s'", err, name)
+		}
+	}
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	for name := range pswds {
+		if vlt.entries[name].Alias != "" {
+			return fmt.Errorf("%w: '%s'", ErrIsAlias, name)
+		}
+	}
+	for name, pswd := range pswds {
+		e := vlt.entries[name]
+		e.setPassword(pswd)
+		vlt.put(name, e)
+	}
+	return nil
+}
+
+// Add stores e under name unless name is already in the vault, and reports
+// whether it did.
+func (vlt *Vault) Add(name string, e Entry) bool {
+	name = NormName(name)
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	if _, ok := vlt.entries[name]; ok {
+		return false
+	}
+	vlt.put(name, e)
+	return true
+}
+
+// put stores e under name, stamping when it was created and modified. The
+// caller must hold the lock.
+func (vlt *Vault) put(name string, e Entry) {
+	now := time.Now().UTC()
+	if _, ok := vlt.entries[name]; !ok {
+		e.Created = now
+	}
+	e.Modified = now
+	vlt.entries[name] = e
+}
+
+// SetNotes stores notes under name, creating a secure note if name isn't in
+// the vault yet.
+func (vlt *Vault) SetNotes(name, notes string) error {
+	name = NormName(name)
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	e := vlt.entries[name]
+	if e.Alias != "" {
+		return ErrIsAlias
+	}
+	e.Notes = notes
+	vlt.put(name, e)
+	return nil
+}
+
+// SetFile stores the contents of a file under name.
+func (vlt *Vault) SetFile(name string, data []byte) error {
+	name = NormName(name)
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	e := vlt.entries[name]
+	if e.Alias != "" {
+		return ErrIsAlias
+	}
+	e.File = data
+	vlt.put(name, e)
+	return nil
+}
+
+// Edit replaces the password, metadata and TOTP secret of the entry name with
+// those of e, keeping its timestamps, file and password history, to which a
+// replaced password is added.
+func (vlt *Vault) Edit(name string, e Entry) error {
+	name = NormName(name)
+	if e.RotateEvery < 0 {
+		return ErrBadRotate
+	}
+	if e.TOTP != "" {
+		secret, err := NormaliseTOTPSecret(e.TOTP)
+		if err != nil {
+			return err
+		}
+		e.TOTP = secret
+	}
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	old, ok := vlt.entries[name]
+	if !ok {
+		return ErrNoSuchValue
+	}
+	if old.Alias != "" {
+		return ErrIsAlias
+	}
+	old.setPassword(e.Password)
+	old.Username, old.URL, old.Notes, old.TOTP = e.Username, e.URL, e.Notes, e.TOTP
+	old.Tags, old.RotateEvery = e.Tags, e.RotateEvery
+	vlt.put(name, old)
+	return nil
+}
+
+// SetTOTP stores the base32 TOTP secret under name.
+func (vlt *Vault) SetTOTP(name, secret string) error {
+	name = NormName(name)
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	secret, err := NormaliseTOTPSecret(secret)
+	if err != nil {
+		return err
+	}
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	e := vlt.entries[name]
+	if e.Alias != "" {
+		return ErrIsAlias
+	}
+	e.TOTP = secret
+	vlt.put(name, e)
+	return nil
+}
+
+// Get returns the entry stored under name.
+func (vlt *Vault) Get(name string) (Entry, error) {
+	name = NormName(name)
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	e, ok := vlt.entries[name]
+	if !ok {
+		return Entry{}, ErrNoSuchValue
+	}
+	return e, nil
+}
+
+// Remove removes the entry stored under name, and the aliases that lead to
+// it, which AliasesTo returns beforehand.
+func (vlt *Vault) Remove(name string) error {
+	name = NormName(name)
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	if _, ok := vlt.entries[name]; !ok {
+		return ErrNoSuchValue
+	}
+	for _, alias := range vlt.aliasesTo(name) {
+		delete(vlt.entries, alias)
+	}
+	delete(vlt.entries, name)
+	return nil
+}
+
+// Clear removes every entry and returns how many there were.
+func (vlt *Vault) Clear() int {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	n := len(vlt.entries)
+	vlt.entries = make(map[string]Entry)
+	return n
+}
+
+// Rename moves the entry stored under oldName to newName, which must not be
+// in use, and repoints aliases of it.
+func (vlt *Vault) Rename(oldName, newName string) error {
+	oldName, newName = NormName(oldName), NormName(newName)
+	if err := CheckName(newName); err != nil {
+		return err
+	}
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	e, ok := vlt.entries[oldName]
+	if !ok {
+		return ErrNoSuchValue
+	}
+	if _, ok := vlt.entries[newName]; ok {
+		return ErrValueExists
+	}
+	delete(vlt.entries, oldName)
+	vlt.entries[newName] = e
+	for name, a := range vlt.entries {
+		if a.Alias == oldName {
+			a.Alias = newName
+			vlt.entries[name] = a
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries.
+func (vlt *Vault) Len() int {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	return len(vlt.entries)
+}
+
+// Names returns the sorted names of every entry.
+func (vlt *Vault) Names() []string {
+	return vlt.Find(func(string) bool { return true })
+}
+
+// Find returns the sorted names for which match returns true. The vault is
+// locked while match is called, so match must not call methods of the vault.
+func (vlt *Vault) Find(match func(name string) bool) []string {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	names := make([]string, 0, len(vlt.entries))
+	for name := range vlt.entries {
+		if match(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stale returns the sorted names of the entries that are stale at now.
+func (vlt *Vault) Stale(now time.Time) []string {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	names := make([]string, 0)
+	for name, e := range vlt.entries {
+		if e.Stale(now) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Entries returns a copy of every entry by name.
+func (vlt *Vault) Entries() map[string]Entry {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	entries := make(map[string]Entry, len(vlt.entries))
+	for name, e := range vlt.entries {
+		entries[name] = e
+	}
+	return entries
+}
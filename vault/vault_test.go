@@ -0,0 +1,126 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func password(pswd string) func() (string, error) {
+	return func() (string, error) { return pswd, nil }
+}
+
+// testVault creates a vault with the master password "pw" in a temporary
+// directory, and returns it and a function to remove it again.
+func testVault(t *testing.T) (*Vault, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "portunus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vlt, err := Create(filepath.Join(dir, "portunus.json"), password("pw"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return vlt, func() {
+		vlt.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestOpenWrongPassword(t *testing.T) {
+	vlt, cleanup := testVault(t)
+	defer cleanup()
+	path := vlt.store.(*FileStore).Path
+	if _, err := Open(path, password("wrong")); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Open with the wrong password: got %v, want %v", err, ErrInvalid)
+	}
+	opened, err := Open(path, password("pw"))
+	if err != nil {
+		t.Fatalf("Open with the right password: %v", err)
+	}
+	opened.Close()
+}
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		old, new string
+		err      error
+		names    []string
+	}{
+		{"a", "c", nil, []string{"b", "c", "link"}},
+		{"a", "b", ErrValueExists, []string{"a", "b", "link"}},
+		{"z", "c", ErrNoSuchValue, []string{"a", "b", "link"}},
+	}
+	for _, test := range tests {
+		vlt, cleanup := testVault(t)
+		vlt.Add("a", Entry{Password: "1"})
+		vlt.Add("b", Entry{Password: "2"})
+		if err := vlt.SetAlias("link", "a"); err != nil {
+			t.Fatal(err)
+		}
+		if err := vlt.Rename(test.old, test.new); !errors.Is(err, test.err) {
+			t.Errorf("Rename(%q, %q) = %v, want %v", test.old, test.new, err, test.err)
+		}
+		if names := vlt.Names(); !reflect.DeepEqual(names, test.names) {
+			t.Errorf("Rename(%q, %q): names = %q, want %q", test.old, test.new, names, test.names)
+		}
+		target := "a"
+		if test.err == nil {
+			target = test.new
+		}
+		if e, _ := vlt.Get("link"); e.Alias != target {
+			t.Errorf("Rename(%q, %q): alias points to %q, want %q", test.old, test.new, e.Alias, target)
+		}
+		cleanup()
+	}
+}
+
+func TestRemoveAliases(t *testing.T) {
+	vlt, cleanup := testVault(t)
+	defer cleanup()
+	vlt.Add("a", Entry{Password: "1"})
+	vlt.Add("b", Entry{Password: "2"})
+	for _, alias := range [][2]string{{"gh", "a"}, {"gh2", "gh"}, {"other", "b"}} {
+		if err := vlt.SetAlias(alias[0], alias[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := vlt.AliasesTo("a"), []string{"gh", "gh2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AliasesTo(a) = %q, want %q", got, want)
+	}
+	if err := vlt.Remove("a"); err != nil {
+		t.Fatal(err)
+	}
+	if names, want := vlt.Names(), []string{"b", "other"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+}
+
+func TestConcurrentSave(t *testing.T) {
+	vlt, cleanup := testVault(t)
+	defer cleanup()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vlt.Add(fmt.Sprint(i), Entry{Password: "pw"})
+			if err := vlt.Save(); err != nil {
+				t.Error(err)
+			}
+			vlt.Changes()
+			vlt.Rekey("pw")
+		}(i)
+	}
+	wg.Wait()
+	if n := vlt.Len(); n != 4 {
+		t.Errorf("%d entries, want 4", n)
+	}
+}
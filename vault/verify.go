@@ -0,0 +1,41 @@
+package vault
+
+import "sort"
+
+// Problem is something wrong with an entry
+type Problem struct {
+	Name    string `json:"name"`
+	Problem string `json:"problem"`
+}
+
+// Verify checks every entry, returning the problems found sorted by name.
+// Decrypting the vault on opening already checks the master password and
+// that the vault file is intact.
+func (vlt *Vault) Verify() []Problem {
+	vlt.lock.Lock()
+	defer vlt.lock.Unlock()
+	problems := []Problem{}
+	for name, e := range vlt.entries {
+		if CheckName(name) != nil {
+			problems = append(problems, Problem{name, "invalid name"})
+		}
+		if e.Alias != "" {
+			if _, err := vlt.resolve(name); err != nil {
+				problems = append(problems, Problem{name, "alias of a missing entry or of itself"})
+			}
+			continue
+		}
+		if e.Password == "" && e.Notes == "" && e.TOTP == "" && e.File == nil {
+			problems = append(problems, Problem{name, "no password, notes, TOTP secret or file"})
+		}
+		if e.TOTP != "" {
+			if _, err := NormaliseTOTPSecret(e.TOTP); err != nil {
+				problems = append(problems, Problem{name, "invalid TOTP secret"})
+			}
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Name < problems[j].Name
+	})
+	return problems
+}
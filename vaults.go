@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const vaultExt = ".json"
+
+var errBadVaultName = errors.New("vault names must be non-empty and not contain path separators")
+
+// vaultsDir is the directory of named vaults, which is created when a named
+// vault is first used
+func vaultsDir() string {
+	return filepath.Join(configDir, "portunus")
+}
+
+// vaultLocation returns the vault file to use, from the first of:
+//
+//  1. path, from -vault
+//  2. the named vault name, from -name
+//  3. the PORTUNUS_VAULT environment variable
+//  4. portunus/portunus.json in $XDG_CONFIG_HOME, if it is set
+//  5. portunus/portunus.json in the user config directory, see os.UserConfigDir
+//
+// If the file of 4 or 5 doesn't exist, portunus.json in the user config
+// directory, where older versions kept the vault, is used if it does.
+func vaultLocation(path, name string, getenv func(key string) string) (string, error) {
+	switch {
+	case path != "":
+		return path, nil
+	case name != "":
+		return namedVaultFile(name)
+	case getenv("PORTUNUS_VAULT") != "":
+		return getenv("PORTUNUS_VAULT"), nil
+	}
+	return defaultVaultFile(getenv), nil
+}
+
+// defaultVaultFile returns the vault file used without -vault, -name or
+// PORTUNUS_VAULT, the last choices of vaultLocation.
+func defaultVaultFile(getenv func(key string) string) string {
+	dir := configDir
+	if xdg := getenv("XDG_CONFIG_HOME"); xdg != "" {
+		dir = xdg
+	}
+	path := filepath.Join(dir, "portunus", "portunus.json")
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		legacy := filepath.Join(configDir, "portunus.json")
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy
+		}
+	}
+	return path
+}
+
+// namedVaultFile returns the location of the named vault.
+func namedVaultFile(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", errBadVaultName
+	}
+	if err := os.MkdirAll(vaultsDir(), 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(vaultsDir(), name+vaultExt), nil
+}
+
+// vaultNames returns the sorted names of the named vaults, leaving out the
+// default vault, which is usually kept among them.
+func vaultNames(getenv func(key string) string) ([]string, error) {
+	fis, err := ioutil.ReadDir(vaultsDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	def := defaultVaultFile(getenv)
+	names := []string{}
+	for _, fi := range fis {
+		name := fi.Name()
+		if filepath.Join(vaultsDir(), name) == def {
+			continue
+		}
+		if fi.Mode().IsRegular() && !strings.HasPrefix(name, ".") && strings.HasSuffix(name, vaultExt) {
+			names = append(names, strings.TrimSuffix(name, vaultExt))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// tempConfigDir points configDir at a new temporary directory until the
+// returned function is called.
+func tempConfigDir(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "portunus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := configDir
+	configDir = dir
+	return dir, func() {
+		configDir = old
+		os.RemoveAll(dir)
+	}
+}
+
+func TestVaultLocation(t *testing.T) {
+	dir, cleanup := tempConfigDir(t)
+	defer cleanup()
+	xdg := filepath.Join(dir, "xdg")
+	tests := []struct {
+		path, name string
+		env        map[string]string
+		want       string
+	}{
+		{"v.json", "work", map[string]string{"PORTUNUS_VAULT": "env.json"}, "v.json"},
+		{"", "work", map[string]string{"PORTUNUS_VAULT": "env.json"}, filepath.Join(dir, "portunus", "work.json")},
+		{"", "", map[string]string{"PORTUNUS_VAULT": "env.json"}, "env.json"},
+		{"", "", map[string]string{"PORTUNUS_VAULT": "env.json", "XDG_CONFIG_HOME": xdg}, "env.json"},
+		{"", "", map[string]string{"XDG_CONFIG_HOME": xdg}, filepath.Join(xdg, "portunus", "portunus.json")},
+		{"", "", nil, filepath.Join(dir, "portunus", "portunus.json")},
+	}
+	for _, test := range tests {
+		got, err := vaultLocation(test.path, test.name, func(key string) string { return test.env[key] })
+		if err != nil {
+			t.Errorf("vaultLocation(%q, %q) with %v: %v", test.path, test.name, test.env, err)
+		} else if got != test.want {
+			t.Errorf("vaultLocation(%q, %q) with %v = %s, want %s", test.path, test.name, test.env, got, test.want)
+		}
+	}
+}
+
+func TestDefaultVaultFileLegacy(t *testing.T) {
+	dir, cleanup := tempConfigDir(t)
+	defer cleanup()
+	xdg := filepath.Join(dir, "xdg")
+	legacy := filepath.Join(dir, "portunus.json")
+	if err := ioutil.WriteFile(legacy, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// the vault where older versions kept it is used until there is one in
+	// the portunus directory
+	for _, env := range []map[string]string{nil, {"XDG_CONFIG_HOME": xdg}} {
+		if got := defaultVaultFile(func(key string) string { return env[key] }); got != legacy {
+			t.Errorf("defaultVaultFile() with %v = %s, want %s", env, got, legacy)
+		}
+	}
+	for _, env := range []map[string]string{nil, {"XDG_CONFIG_HOME": xdg}} {
+		base := dir
+		if env != nil {
+			base = xdg
+		}
+		want := filepath.Join(base, "portunus", "portunus.json")
+		if err := os.MkdirAll(filepath.Dir(want), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(want, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if got := defaultVaultFile(func(key string) string { return env[key] }); got != want {
+			t.Errorf("defaultVaultFile() with %v = %s, want %s", env, got, want)
+		}
+	}
+}
+
+func TestVaultNames(t *testing.T) {
+	dir, cleanup := tempConfigDir(t)
+	defer cleanup()
+	if err := os.MkdirAll(vaultsDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"portunus.json", "work.json", "home.json", ".hidden.json", "profiles"} {
+		if err := ioutil.WriteFile(filepath.Join(vaultsDir(), name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		env  map[string]string
+		want []string
+	}{
+		{nil, []string{"home", "work"}},
+		{map[string]string{"XDG_CONFIG_HOME": dir}, []string{"home", "work"}},
+		// the default vault is elsewhere, so this is a named vault
+		{map[string]string{"XDG_CONFIG_HOME": filepath.Join(dir, "xdg")}, []string{"home", "portunus", "work"}},
+	}
+	for _, test := range tests {
+		got, err := vaultNames(func(key string) string { return test.env[key] })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("vaultNames() with %v = %q, want %q", test.env, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/patrickmcnamara/portunus/vault"
+)
+
+// version and commit describe the build, and are set when building releases
+// with e.g. -ldflags "-X main.version=v1.2.0 -X main.commit=abc1234"
+var (
+	version = "dev"
+	commit  = "unknown"
+)
+
+// buildVersion returns version or, for a dev build installed with
+// 'go install' at a tagged version, the module version.
+func buildVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		return bi.Main.Version
+	}
+	return version
+}
+
+// printVersion prints the version, commit, Go version and vault format
+// version of this build.
+func printVersion() error {
+	if jsonOutput {
+		return printJSON(struct {
+			Version     string `json:"version"`
+			Commit      string `json:"commit"`
+			Go          string `json:"go"`
+			VaultFormat int    `json:"vault_format"`
+		}{buildVersion(), commit, runtime.Version(), vault.FormatVersion})
+	}
+	fmt.Printf("portunus %s (commit %s, %s, vault format %d)\n", buildVersion(), commit, runtime.Version(), vault.FormatVersion)
+	return nil
+}